@@ -0,0 +1,101 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+type benchInner struct {
+	Colour uint
+	Label  string
+}
+
+type benchRecord struct {
+	Name    string
+	Age     int
+	Sane    bool
+	Lengths []int
+	Inner   benchInner
+	Missing *benchInner
+	Tags    map[string]int
+	Blob    []byte
+	Sizes   [3]int
+}
+
+// benchStream returns a gob stream of n records
+func benchStream(b *testing.B, n int) []byte {
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < n; i++ {
+		r := benchRecord{
+			Name:    "goober",
+			Age:     i,
+			Sane:    i%2 == 0,
+			Lengths: []int{8, 1001, i},
+			Inner:   benchInner{Colour: 12345678, Label: "red"},
+			Tags:    map[string]int{"a": 1, "b": i},
+			Blob:    []byte{9, 8, 7, 0x55},
+			Sizes:   [3]int{1, 2, 3},
+		}
+		if err := enc.Encode(r); err != nil {
+			b.Fatal("shame", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// benchScan reports the time and allocations per record of scanning the stream
+func benchScan(b *testing.B, zeroCopy bool) {
+	const records = 1000
+	stream := benchStream(b, records)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(stream)) / records)
+	b.ResetTimer()
+	var d *decoder
+	for i := 0; i < b.N; i++ {
+		if i%records == 0 {
+			d = New(bytes.NewReader(stream))
+			d.SetZeroCopy(zeroCopy)
+		}
+		if !d.Scan() {
+			b.Fatal("could not scan", d.Err())
+		}
+	}
+	b.StopTimer()
+	// check the last record decoded, and its type survived the scans
+	v := d.Value()
+	if got := v.TypeName(); got != "benchRecord" {
+		b.Fatal("got type", got)
+	}
+	if name, _ := v.Field("Name"); name.ToString() != "goober" {
+		b.Fatal("got name", name.ToString())
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	benchScan(b, false)
+}
+
+func BenchmarkScanZeroCopy(b *testing.B) {
+	benchScan(b, true)
+}
+
+func BenchmarkJSON(b *testing.B) {
+	const records = 1000
+	stream := benchStream(b, records)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var d *decoder
+	for i := 0; i < b.N; i++ {
+		if i%records == 0 {
+			d = New(bytes.NewReader(stream))
+		}
+		if !d.Scan() {
+			b.Fatal("could not scan", d.Err())
+		}
+		if _, err := d.JSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
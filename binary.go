@@ -0,0 +1,95 @@
+package goblin
+
+import (
+	"errors"
+)
+
+// MsgPack returns the result of the last Scan encoded as MessagePack. Unlike
+// JSON it keeps ints and uints apart, floats that are whole numbers as floats,
+// []byte as bin rather than base64 strings and map keys as their typed
+// values, with struct keys as maps. Structs are maps of their field names in
+// declared order.
+func (d *decoder) MsgPack() ([]byte, error) {
+	if d.lastVal == nil {
+		return nil, errors.New("can not return msgpack representation of none existant object")
+	}
+	e := &msgpackEncoder{}
+	encodeVal(e, d.lastVal, d.opts)
+	return e.b, nil
+}
+
+// CBOR returns the result of the last Scan encoded as CBOR, keeping the same
+// types as MsgPack does
+func (d *decoder) CBOR() ([]byte, error) {
+	if d.lastVal == nil {
+		return nil, errors.New("can not return cbor representation of none existant object")
+	}
+	e := &cborEncoder{}
+	encodeVal(e, d.lastVal, d.opts)
+	return e.b, nil
+}
+
+// binEncoder writes the items of a binary format, arrays and maps are
+// written as a header of their length followed by their elements
+type binEncoder interface {
+	null()
+	bool(b bool)
+	int(i int64)
+	uint(u uint64)
+	float(f float64)
+	bytes(b []byte)
+	string(s string)
+	array(n int)
+	mapOf(n int)
+}
+
+// encodeVal writes v to e, rendering absent fields as o says
+func encodeVal(e binEncoder, v *val, o objOpts) {
+	switch v.t {
+	case tBool:
+		e.bool(v.ToBool())
+	case tInt:
+		e.int(v.ToInt())
+	case tUint:
+		e.uint(v.ToUint())
+	case tFloat:
+		e.float(v.ToFloat())
+	case tBytes:
+		e.bytes(v.da)
+	case tString:
+		e.string(string(v.da))
+	case tSlice:
+		e.array(len(v.sl.els))
+		for i := range v.sl.els {
+			encodeVal(e, &v.sl.els[i], o)
+		}
+	case tMap:
+		e.mapOf(len(v.ma.keys))
+		for _, k := range v.ma.sortedKeys() {
+			encodeVal(e, &k, o)
+			el := v.ma.els[k.key()]
+			encodeVal(e, &el, o)
+		}
+	case tStruct:
+		n := 0
+		for _, f := range v.st {
+			if f.nonZero || o.absent != AbsentOmit {
+				n++
+			}
+		}
+		e.mapOf(n)
+		for i := range v.st {
+			f := &v.st[i]
+			switch {
+			case f.nonZero || f.absentAs(o.absent) == AbsentZero:
+				e.string(f.name)
+				encodeVal(e, &f.v, o)
+			case f.absentAs(o.absent) == AbsentNull:
+				e.string(f.name)
+				e.null()
+			}
+		}
+	default:
+		e.null()
+	}
+}
@@ -0,0 +1,251 @@
+package goblin
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// kv is a map entry in the trees the test readers make, as struct keys are
+// maps which can not be go map keys
+type kv struct {
+	k, v interface{}
+}
+
+// binReader reads MessagePack or CBOR into a tree of bool, int64, uint64,
+// float64, []byte, string, nil, []interface{} and []kv
+type binReader struct {
+	b   []byte
+	err error
+}
+
+func (r *binReader) next(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		if r.err == nil {
+			r.err = fmt.Errorf("short by %d bytes", n-len(r.b))
+		}
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *binReader) uintN(n int) uint64 {
+	var u uint64
+	for _, c := range r.next(n) {
+		u = u<<8 | uint64(c)
+	}
+	return u
+}
+
+func (r *binReader) items(n int, item func() interface{}) []interface{} {
+	a := []interface{}{}
+	for i := 0; i < n; i++ {
+		a = append(a, item())
+	}
+	return a
+}
+
+func (r *binReader) pairs(n int, item func() interface{}) []kv {
+	m := []kv{}
+	for i := 0; i < n; i++ {
+		k := item()
+		m = append(m, kv{k, item()})
+	}
+	return m
+}
+
+func (r *binReader) msgpack() interface{} {
+	c := r.next(1)[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c)
+	case c >= 0xe0:
+		return int64(int8(c))
+	case c&0xf0 == 0x80:
+		return r.pairs(int(c&0x0f), r.msgpack)
+	case c&0xf0 == 0x90:
+		return r.items(int(c&0x0f), r.msgpack)
+	case c&0xe0 == 0xa0:
+		return string(r.next(int(c & 0x1f)))
+	}
+	switch c {
+	case 0xc0:
+		return nil
+	case 0xc2, 0xc3:
+		return c == 0xc3
+	case 0xc4, 0xc5, 0xc6:
+		return append([]byte{}, r.next(int(r.uintN(1<<(c-0xc4))))...)
+	case 0xcb:
+		return math.Float64frombits(r.uintN(8))
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return r.uintN(1 << (c - 0xcc))
+	case 0xd0:
+		return int64(int8(r.uintN(1)))
+	case 0xd1:
+		return int64(int16(r.uintN(2)))
+	case 0xd2:
+		return int64(int32(r.uintN(4)))
+	case 0xd3:
+		return int64(r.uintN(8))
+	case 0xd9, 0xda, 0xdb:
+		return string(r.next(int(r.uintN(1 << (c - 0xd9)))))
+	case 0xdc, 0xdd:
+		return r.items(int(r.uintN(2<<(c-0xdc))), r.msgpack)
+	case 0xde, 0xdf:
+		return r.pairs(int(r.uintN(2<<(c-0xde))), r.msgpack)
+	}
+	r.err = fmt.Errorf("unexpected msgpack format %x", c)
+	return nil
+}
+
+func (r *binReader) cbor() interface{} {
+	c := r.next(1)[0]
+	major, info := c>>5, c&0x1f
+	arg := uint64(info)
+	if info >= 24 && info <= 27 {
+		arg = r.uintN(1 << (info - 24))
+	}
+	switch major {
+	case 0:
+		return arg
+	case 1:
+		return -1 - int64(arg)
+	case 2:
+		return append([]byte{}, r.next(int(arg))...)
+	case 3:
+		return string(r.next(int(arg)))
+	case 4:
+		return r.items(int(arg), r.cbor)
+	case 5:
+		return r.pairs(int(arg), r.cbor)
+	}
+	switch info {
+	case 20, 21:
+		return info == 21
+	case 22:
+		return nil
+	case 27:
+		return math.Float64frombits(arg)
+	}
+	r.err = fmt.Errorf("unexpected cbor item %x", c)
+	return nil
+}
+
+// tree makes the tree the readers should return for v, ints are signed if
+// the format can tell
+func tree(v Value, cbor bool) interface{} {
+	switch v.Kind() {
+	case Bool:
+		return v.ToBool()
+	case Int:
+		if i := v.ToInt(); cbor && i >= 0 {
+			return uint64(i)
+		}
+		return v.ToInt()
+	case Uint:
+		if u := v.ToUint(); !cbor && u <= 127 {
+			return int64(u) // a positive fixint
+		}
+		return v.ToUint()
+	case Float:
+		return v.ToFloat()
+	case Bytes:
+		return append([]byte{}, v.ToBytes()...)
+	case String:
+		return v.ToString()
+	case Slice, Array:
+		a := []interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			a = append(a, tree(v.Index(i), cbor))
+		}
+		return a
+	case Map:
+		m := []kv{}
+		for _, k := range v.MapKeys() {
+			e, _ := v.MapIndex(k.v.key())
+			m = append(m, kv{tree(k, cbor), tree(e, cbor)})
+		}
+		return m
+	case Struct:
+		m := []kv{}
+		for _, name := range v.Fields() {
+			f, _ := v.Field(name)
+			m = append(m, kv{name, tree(f, cbor)})
+		}
+		return m
+	}
+	return nil
+}
+
+func TestBinaryFormats(t *testing.T) {
+	fixtures := []interface{}{
+		benchRecord{
+			Name:    "goober",
+			Age:     -19,
+			Sane:    true,
+			Lengths: []int{8, 1001, -70000, math.MinInt64},
+			Inner:   benchInner{Colour: 12345678, Label: "red"},
+			Tags:    map[string]int{"a": 1, "b": 300},
+			Blob:    []byte{9, 8, 7, 0x55},
+			Sizes:   [3]int{1, 2, 3},
+		},
+		cfgValue,
+		shapes{
+			ByName:  map[string]point{"a": {1, 2}},
+			ByPoint: map[point]int{{1, 2}: 3, {0, 5}: 4},
+			Nested:  []map[string][]point{{"x": {{1, 1}, {2, 2}}}},
+		},
+		map[uint64]float64{math.MaxUint64: 2, 200: -0.5},
+		make([]bool, 20), // beyond the fixarray length
+	}
+	for i, fx := range fixtures {
+		d := scanOne(t, fx)
+
+		b, err := d.MsgPack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &binReader{b: b}
+		got := r.msgpack()
+		if r.err != nil || len(r.b) != 0 {
+			t.Fatalf("%d: bad msgpack %v %d bytes left", i, r.err, len(r.b))
+		}
+		if exp := tree(d.Value(), false); !reflect.DeepEqual(got, exp) {
+			t.Errorf("%d: msgpack got\n%#v\nexpected\n%#v", i, got, exp)
+		}
+
+		b, err = d.CBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = &binReader{b: b}
+		got = r.cbor()
+		if r.err != nil || len(r.b) != 0 {
+			t.Fatalf("%d: bad cbor %v %d bytes left", i, r.err, len(r.b))
+		}
+		if exp := tree(d.Value(), true); !reflect.DeepEqual(got, exp) {
+			t.Errorf("%d: cbor got\n%#v\nexpected\n%#v", i, got, exp)
+		}
+	}
+
+	// the exact encodings of a few values
+	d := scanOne(t, struct {
+		I int
+		U uint
+		F float64
+		B []byte
+	}{I: -300, U: 300, F: 1, B: []byte("x")})
+	b, _ := d.MsgPack()
+	exp := "84a149d1fed4a155cd012ca146cb3ff0000000000000a142c40178"
+	if got := fmt.Sprintf("%x", b); got != exp {
+		t.Errorf("msgpack got %s", got)
+	}
+	b, _ = d.CBOR()
+	exp = "a4614939012b615519012c6146fb3ff000000000000061424178"
+	if got := fmt.Sprintf("%x", b); got != exp {
+		t.Errorf("cbor got %s", got)
+	}
+}
@@ -0,0 +1,86 @@
+package goblin
+
+import "math"
+
+// the CBOR major types
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborSimple = 7 << 5
+)
+
+// cborEncoder writes the CBOR format of RFC 8949, with definite lengths and
+// the shortest form of each argument
+type cborEncoder struct {
+	b []byte
+}
+
+func (e *cborEncoder) null() {
+	e.b = append(e.b, cborSimple|22)
+}
+
+func (e *cborEncoder) bool(b bool) {
+	if b {
+		e.b = append(e.b, cborSimple|21)
+		return
+	}
+	e.b = append(e.b, cborSimple|20)
+}
+
+func (e *cborEncoder) int(i int64) {
+	if i < 0 {
+		e.head(cborNegInt, uint64(-1-i))
+		return
+	}
+	e.head(cborUint, uint64(i))
+}
+
+func (e *cborEncoder) uint(u uint64) {
+	e.head(cborUint, u)
+}
+
+func (e *cborEncoder) float(f float64) {
+	e.b = append(e.b, cborSimple|27)
+	e.b = appendUint64(e.b, math.Float64bits(f))
+}
+
+func (e *cborEncoder) bytes(b []byte) {
+	e.head(cborBytes, uint64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *cborEncoder) string(s string) {
+	e.head(cborText, uint64(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *cborEncoder) array(n int) {
+	e.head(cborArray, uint64(n))
+}
+
+func (e *cborEncoder) mapOf(n int) {
+	e.head(cborMap, uint64(n))
+}
+
+// head writes the major type with its argument u
+func (e *cborEncoder) head(major byte, u uint64) {
+	switch {
+	case u < 24:
+		e.b = append(e.b, major|byte(u))
+	case u <= math.MaxUint8:
+		e.b = append(e.b, major|24, byte(u))
+	case u <= math.MaxUint16:
+		e.b = append(e.b, major|25)
+		e.b = appendUint16(e.b, uint16(u))
+	case u <= math.MaxUint32:
+		e.b = append(e.b, major|26)
+		e.b = appendUint32(e.b, uint32(u))
+	default:
+		e.b = append(e.b, major|27)
+		e.b = appendUint64(e.b, u)
+	}
+}
@@ -0,0 +1,291 @@
+// Command goblin inspects gob files without the go types that wrote them.
+//
+//	goblin json file.gob              print each value as indented json
+//	goblin yaml file.gob              print each value as a yaml document
+//	goblin toml file.gob              print each struct or map value as toml
+//	goblin msgpack file.gob           write the values as a stream of msgpack
+//	goblin cbor file.gob              write the values as a cbor sequence
+//	goblin types file.gob             print the go types of the values
+//	goblin go file.gob                print each value as a go composite literal
+//	goblin schema file.gob            write the type messages, to decode bare values with
+//	goblin compat old.gob new.gob     check that readers of old.gob can decode new.gob
+//	goblin diff a.gob b.gob           print the differences between the values
+//	goblin lint file.gob              check the stream against the gob encoding rules
+//	goblin grep expr file.gob         print the values that match the filter expression
+//	goblin csv file.gob               print struct values as csv rows
+//	goblin tsv file.gob               print struct values as tab separated rows
+//
+// A file name of - reads from stdin. compat exits with 1 if there are breaking
+// changes, diff exits with 1 if there are differences, lint exits with 1 if
+// there are violations, grep exits with 1 if no values match, and all commands
+// exit with 2 on errors. See goblin.ParseFilter for the grep expressions.
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/danmux/goblin"
+)
+
+const usage = `usage: goblin <command> [arguments]
+
+commands:
+  json file.gob            print each value as indented json
+  yaml file.gob            print each value as a yaml document
+  toml file.gob            print each struct or map value as toml
+  msgpack file.gob         write the values as a stream of msgpack
+  cbor file.gob            write the values as a cbor sequence
+  types file.gob           print the go types of the values
+  go file.gob              print each value as a go composite literal
+  schema file.gob          write the type messages, to decode bare values with
+  compat old.gob new.gob   check that readers of old.gob can decode new.gob
+  diff a.gob b.gob         print the differences between the values
+  lint file.gob            check the stream against the gob encoding rules
+  grep expr file.gob       print the values that match the filter expression
+  csv file.gob             print struct values as csv rows
+  tsv file.gob             print struct values as tab separated rows
+`
+
+// commands maps the command names to their funcs and the number of files
+// they need, the first being the expression for the commands that take one
+var commands = map[string]struct {
+	files int
+	expr  bool
+	run   func(files []io.Reader) (int, error)
+}{
+	"json":    {1, false, jsonCmd},
+	"yaml":    {1, false, yamlCmd},
+	"toml":    {1, false, tomlCmd},
+	"msgpack": {1, false, binaryCmd(false)},
+	"cbor":    {1, false, binaryCmd(true)},
+	"types":   {1, false, typesCmd},
+	"go":      {1, false, goCmd},
+	"schema":  {1, false, schemaCmd},
+	"compat":  {2, false, compatCmd},
+	"diff":    {2, false, diffCmd},
+	"lint":    {1, false, lintCmd},
+	"grep":    {2, true, grepCmd},
+	"csv":     {1, false, csvCmd(',')},
+	"tsv":     {1, false, csvCmd('\t')},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok || len(args)-1 != cmd.files {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	var files []io.Reader
+	names := args[1:]
+	if cmd.expr {
+		files = append(files, strings.NewReader(names[0]))
+		names = names[1:]
+	}
+	for _, name := range names {
+		if name == "-" {
+			files = append(files, os.Stdin)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+	code, err := cmd.run(files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return code
+}
+
+func jsonCmd(files []io.Reader) (int, error) {
+	d := goblin.New(files[0])
+	for d.Scan() {
+		b, err := d.JSON()
+		if err != nil {
+			return 0, err
+		}
+		fmt.Printf("%s\n", b)
+	}
+	return 0, d.Err()
+}
+
+// yamlCmd prints the values as a stream of yaml documents
+func yamlCmd(files []io.Reader) (int, error) {
+	d := goblin.New(files[0])
+	for d.Scan() {
+		b, err := d.YAML()
+		if err != nil {
+			return 0, err
+		}
+		fmt.Printf("---\n%s", b)
+	}
+	return 0, d.Err()
+}
+
+// tomlCmd prints the values as toml documents separated by blank lines
+func tomlCmd(files []io.Reader) (int, error) {
+	d := goblin.New(files[0])
+	for n := 0; d.Scan(); n++ {
+		b, err := d.TOML()
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s", b)
+	}
+	return 0, d.Err()
+}
+
+// binaryCmd returns a command that writes the values one after another as
+// msgpack, or cbor
+func binaryCmd(cbor bool) func(files []io.Reader) (int, error) {
+	return func(files []io.Reader) (int, error) {
+		d := goblin.New(files[0])
+		for d.Scan() {
+			b, err := d.MsgPack()
+			if cbor {
+				b, err = d.CBOR()
+			}
+			if err != nil {
+				return 0, err
+			}
+			if _, err := os.Stdout.Write(b); err != nil {
+				return 0, err
+			}
+		}
+		return 0, d.Err()
+	}
+}
+
+func typesCmd(files []io.Reader) (int, error) {
+	d := goblin.New(files[0])
+	if !d.Scan() && d.Err() != nil {
+		return 0, d.Err()
+	}
+	d.WriteTypes(os.Stdout)
+	return 0, nil
+}
+
+func goCmd(files []io.Reader) (int, error) {
+	d := goblin.New(files[0])
+	for d.Scan() {
+		fmt.Printf("%#v\n", d.Value())
+	}
+	return 0, d.Err()
+}
+
+func schemaCmd(files []io.Reader) (int, error) {
+	s, err := goblin.ExtractSchema(files[0])
+	if err != nil {
+		return 0, err
+	}
+	_, err = os.Stdout.Write(s)
+	return 0, err
+}
+
+func compatCmd(files []io.Reader) (int, error) {
+	changes, err := goblin.Compat(goblin.New(files[0]), goblin.New(files[1]))
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if goblin.Breaking(changes) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// lintCmd prints the violations of the gob encoding rules
+func lintCmd(files []io.Reader) (int, error) {
+	vs, err := goblin.Lint(files[0])
+	for _, v := range vs {
+		fmt.Println(v)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(vs) > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// grepCmd prints the values that pass the filter expression in the first
+// file as json
+func grepCmd(files []io.Reader) (int, error) {
+	expr, err := ioutil.ReadAll(files[0])
+	if err != nil {
+		return 0, err
+	}
+	f, err := goblin.ParseFilter(string(expr))
+	if err != nil {
+		return 0, fmt.Errorf("bad expression: %v", err)
+	}
+	d := goblin.New(files[1])
+	d.SetFilter(f)
+	code := 1
+	for d.Scan() {
+		b, err := d.JSON()
+		if err != nil {
+			return 0, err
+		}
+		fmt.Printf("%s\n", b)
+		code = 0
+	}
+	return code, d.Err()
+}
+
+// diffCmd compares the values of the two files in turn
+func diffCmd(files []io.Reader) (int, error) {
+	a, b := goblin.New(files[0]), goblin.New(files[1])
+	code := 0
+	for n := 0; ; n++ {
+		aok, bok := a.Scan(), b.Scan()
+		if a.Err() != nil {
+			return 0, a.Err()
+		}
+		if b.Err() != nil {
+			return 0, b.Err()
+		}
+		if !aok && !bok {
+			return code, nil
+		}
+		deltas := goblin.Diff(a.Value(), b.Value())
+		if len(deltas) == 0 {
+			continue
+		}
+		code = 1
+		fmt.Printf("value %d\n", n)
+		for _, dl := range deltas {
+			fmt.Printf("  %s\n", dl)
+		}
+	}
+}
+
+// csvCmd returns a command that writes the rows with the comma delimiter
+func csvCmd(comma rune) func(files []io.Reader) (int, error) {
+	return func(files []io.Reader) (int, error) {
+		return 0, goblin.New(files[0]).WriteCSV(os.Stdout, goblin.CSVOptions{Comma: comma})
+	}
+}
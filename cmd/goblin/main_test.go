@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type rec struct {
+	Name string
+	Age  int
+}
+
+// writeGob writes the values to a gob file in dir, returning its name
+func writeGob(t *testing.T, dir string, vs ...interface{}) string {
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for _, v := range vs {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	name := filepath.Join(dir, "recs.gob")
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// capture runs the command line returning its exit code and what it printed
+func capture(t *testing.T, args ...string) (int, string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	out := make(chan string)
+	go func() {
+		b := &bytes.Buffer{}
+		io.Copy(b, r)
+		out <- b.String()
+	}()
+	code := run(args)
+	os.Stdout, os.Stderr = stdout, stderr
+	w.Close()
+	return code, <-out
+}
+
+func TestGrep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goblin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := writeGob(t, dir, rec{Name: "ann", Age: 30}, rec{Name: "bob", Age: 20}, rec{Name: "cal", Age: 40})
+
+	cases := []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{args: []string{"grep", "Age > 25", name}, code: 0, out: "\"ann\"\n\"cal\"\n"},
+		{args: []string{"grep", `Name == "bob"`, name}, code: 0, out: "\"bob\"\n"},
+		{args: []string{"grep", "Age > 50", name}, code: 1},
+		{args: []string{"grep", "Age >", name}, code: 2, out: "bad expression"},
+		{args: []string{"grep", "Age > 25"}, code: 2, out: "usage"},
+		{args: []string{"grep", "Age > 25", name, name}, code: 2, out: "usage"},
+		{args: []string{"grep", "Age > 25", filepath.Join(dir, "none.gob")}, code: 2, out: "none.gob"},
+		{args: []string{"nope", name}, code: 2, out: "usage"},
+		{args: nil, code: 2, out: "usage"},
+	}
+	for _, c := range cases {
+		code, out := capture(t, c.args...)
+		if code != c.code {
+			t.Errorf("%q: got exit code %d: %s", c.args, code, out)
+		}
+		// the matches are json objects so just check the names
+		if c.code == 0 {
+			var names []string
+			for _, l := range strings.Split(out, "\n") {
+				if strings.Contains(l, `"Name"`) {
+					names = append(names, strings.TrimSuffix(strings.Fields(l)[1], ","))
+				}
+			}
+			out = strings.Join(names, "\n") + "\n"
+		}
+		if !strings.Contains(out, c.out) {
+			t.Errorf("%q: got %q", c.args, out)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package goblin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ChangeType is the type of a difference found by Compat
+type ChangeType int
+
+const (
+	// FieldAdded is a struct field only in the newer stream, old readers ignore it
+	FieldAdded ChangeType = iota
+	// FieldRemoved is a struct field only in the older stream, old readers leave it zero
+	FieldRemoved
+	// TypeRenamed is a struct whose name changed, gob matches structs by their fields so this is safe
+	TypeRenamed
+	// TypeChanged is a value whose kind changed, which gob can not convert
+	TypeChanged
+	// KeyChanged is a map whose key kind changed, which gob can not convert
+	KeyChanged
+	// LenChanged is an array whose length changed, which gob can not decode
+	LenChanged
+	// NoFieldsMatched is a struct that has no fields in common, which gob refuses to decode
+	NoFieldsMatched
+)
+
+var changeNames = []string{
+	FieldAdded:      "added",
+	FieldRemoved:    "removed",
+	TypeRenamed:     "renamed",
+	TypeChanged:     "type changed",
+	KeyChanged:      "key changed",
+	LenChanged:      "length changed",
+	NoFieldsMatched: "no fields matched",
+}
+
+func (c ChangeType) String() string {
+	if c < 0 || int(c) >= len(changeNames) {
+		return "unknown"
+	}
+	return changeNames[c]
+}
+
+// Change is a difference between the types of two gob streams
+type Change struct {
+	Path     string     // the dotted path to the value, from the root or a type name, [] for elements and [key] for map keys
+	Type     ChangeType // what changed
+	Old      string     // the type in the older stream, empty if added
+	New      string     // the type in the newer stream, empty if removed
+	Breaking bool       // data from the newer stream can not be decoded by readers of the older
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "."
+	}
+	sev := "ok"
+	if c.Breaking {
+		sev = "BREAKING"
+	}
+	switch c.Type {
+	case FieldAdded:
+		return fmt.Sprintf("%s: %s %s %s", sev, path, c.Type, c.New)
+	case FieldRemoved:
+		return fmt.Sprintf("%s: %s %s %s", sev, path, c.Type, c.Old)
+	}
+	return fmt.Sprintf("%s: %s %s from %s to %s", sev, path, c.Type, c.Old, c.New)
+}
+
+// Breaking returns true if any of the changes are breaking
+func Breaking(cs []Change) bool {
+	for _, c := range cs {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Compat compares the types of the values in the gob stream read by newer
+// with those in older, reporting the changes that affect whether readers of
+// the older stream can decode the newer, following the encoding/gob rules.
+// The types of the first values are compared, then the named types that are
+// in both streams, matched by name, with paths starting at the type name.
+// Both streams are scanned to the end so that all their types are known.
+func Compat(older, newer *decoder) ([]Change, error) {
+	ot, err := older.rootType()
+	if err != nil {
+		return nil, fmt.Errorf("older stream: %v", err)
+	}
+	nt, err := newer.rootType()
+	if err != nil {
+		return nil, fmt.Errorf("newer stream: %v", err)
+	}
+	c := comparer{
+		o:    older,
+		n:    newer,
+		seen: map[[2]typeID]bool{},
+	}
+	c.compare("", ot, nt)
+
+	if err := older.scanAll(); err != nil {
+		return nil, fmt.Errorf("older stream: %v", err)
+	}
+	if err := newer.scanAll(); err != nil {
+		return nil, fmt.Errorf("newer stream: %v", err)
+	}
+	named := newer.namedTypes()
+	for _, id := range older.userTypes() {
+		name := older.idToType(int(id))
+		if nid, ok := named[name]; ok {
+			c.compare(name, id, nid)
+		}
+	}
+	return c.changes, nil
+}
+
+// scanAll scans the rest of the stream, loading all its types
+func (d *decoder) scanAll() error {
+	for d.Scan() {
+	}
+	return d.Err()
+}
+
+// userTypes returns the ids of the user types in the order they were defined
+func (d *decoder) userTypes() []typeID {
+	var ids []typeID
+	for id := range d.types {
+		if id >= minUserType {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// namedTypes returns the user types that gob sent a name for, by their name
+func (d *decoder) namedTypes() map[string]typeID {
+	named := map[string]typeID{}
+	for _, id := range d.userTypes() {
+		if _, def, ok := d.wireDef(id); ok && isIdent(defName(def)) {
+			named[d.idToType(int(id))] = id
+		}
+	}
+	return named
+}
+
+// rootType returns the type id of the values in the stream, scanning the
+// first value if nothing has been scanned yet
+func (d *decoder) rootType() (typeID, error) {
+	if d.lastVal == nil && len(d.types) == 0 && !d.Scan() && d.Err() != nil {
+		return 0, d.Err()
+	}
+	if d.lastVal == nil {
+		return 0, errors.New("no values in the gob stream")
+	}
+	if d.lastVal.id != 0 {
+		return d.lastVal.id, nil
+	}
+	return d.lastVal.t, nil
+}
+
+// comparer walks two type graphs in step
+type comparer struct {
+	o, n    *decoder
+	seen    map[[2]typeID]bool // pairs already compared, types can be recursive
+	changes []Change
+}
+
+func (c *comparer) add(path string, ct ChangeType, ot, nt typeID, breaking bool) {
+	ch := Change{
+		Path:     path,
+		Type:     ct,
+		Breaking: breaking,
+	}
+	if ot != 0 {
+		ch.Old = c.o.idToType(int(ot))
+	}
+	if nt != 0 {
+		ch.New = c.n.idToType(int(nt))
+	}
+	c.changes = append(c.changes, ch)
+}
+
+func (c *comparer) compare(path string, ot, nt typeID) {
+	pair := [2]typeID{ot, nt}
+	if c.seen[pair] {
+		return
+	}
+	c.seen[pair] = true
+
+	oKind, nKind := c.o.typeKind(ot), c.n.typeKind(nt)
+	if oKind != nKind {
+		c.add(path, TypeChanged, ot, nt, true)
+		return
+	}
+	if oKind < Slice { // primitives of the same kind are always compatible
+		return
+	}
+
+	_, odef, _ := c.o.wireDef(ot)
+	w, ndef, _ := c.n.wireDef(nt)
+	switch oKind {
+	case Array:
+		if defLen(odef) != defLen(ndef) {
+			c.add(path, LenChanged, ot, nt, true)
+		}
+		c.compare(path+"[]", defElem(w, odef), defElem(w, ndef))
+	case Slice:
+		c.compare(path+"[]", defElem(w, odef), defElem(w, ndef))
+	case Map:
+		okey, nkey := defKey(odef), defKey(ndef)
+		if c.o.typeKind(okey) != c.n.typeKind(nkey) {
+			c.add(path+"[key]", KeyChanged, okey, nkey, true)
+		} else {
+			c.compare(path+"[key]", okey, nkey)
+		}
+		c.compare(path+"[]", defElem(w, odef), defElem(w, ndef))
+	case Struct:
+		c.compareStruct(path, ot, nt, odef, ndef)
+	}
+}
+
+func (c *comparer) compareStruct(path string, ot, nt typeID, odef, ndef val) {
+	if defName(odef) != defName(ndef) {
+		c.add(path, TypeRenamed, ot, nt, false)
+	}
+	ofs, nfs := defFields(odef), defFields(ndef)
+	newFields := map[string]typeID{}
+	for _, f := range nfs {
+		newFields[f.name] = f.id
+	}
+	matched := 0
+	for _, f := range ofs {
+		fp := joinPath(path, f.name)
+		nid, ok := newFields[f.name]
+		if !ok {
+			c.add(fp, FieldRemoved, f.id, 0, false)
+			continue
+		}
+		matched++
+		c.compare(fp, f.id, nid)
+	}
+	oldFields := map[string]bool{}
+	for _, f := range ofs {
+		oldFields[f.name] = true
+	}
+	for _, f := range nfs {
+		if !oldFields[f.name] {
+			c.add(joinPath(path, f.name), FieldAdded, 0, f.id, false)
+		}
+	}
+	if matched == 0 && len(ofs) > 0 {
+		c.add(path, NoFieldsMatched, ot, nt, true)
+	}
+}
+
+// joinPath adds the field name to the dotted path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
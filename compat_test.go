@@ -0,0 +1,128 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+)
+
+func encoded(t *testing.T, v interface{}) *decoder {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		t.Fatal("shame", err)
+	}
+	return New(buf)
+}
+
+func TestCompat(t *testing.T) {
+	type inner struct {
+		Colour uint
+	}
+	type record struct {
+		Name   string
+		Age    int
+		Gone   bool
+		Inner  inner
+		Tags   map[string]int
+		Sizes  [3]int
+		Height float64
+	}
+	type inner2 struct {
+		Colour uint16
+	}
+	type record2 struct {
+		Name   string
+		Age    string // can not be converted
+		Inner  inner2 // renamed but compatible
+		Tags   map[int]int
+		Sizes  [2]int
+		Height float32 // widths are not sent
+		Added  []string
+	}
+
+	changes, err := Compat(
+		encoded(t, record{Name: "a"}),
+		encoded(t, record2{Name: "a"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Change{
+		{Path: "", Type: TypeRenamed, Old: "record", New: "record2"},
+		{Path: "Age", Type: TypeChanged, Old: "int64", New: "string", Breaking: true},
+		{Path: "Gone", Type: FieldRemoved, Old: "bool"},
+		{Path: "Inner", Type: TypeRenamed, Old: "inner", New: "inner2"},
+		{Path: "Tags[key]", Type: KeyChanged, Old: "string", New: "int64", Breaking: true},
+		{Path: "Sizes", Type: LenChanged, Old: "[3]int64", New: "[2]int64", Breaking: true},
+		{Path: "Added", Type: FieldAdded, New: "[]string"},
+	}
+	if !reflect.DeepEqual(changes, exp) {
+		for _, c := range changes {
+			t.Log(c)
+		}
+		t.Fatal("unexpected changes")
+	}
+	if !Breaking(changes) {
+		t.Error("should be breaking")
+	}
+
+	// the same types are compatible
+	changes, err = Compat(encoded(t, record{}), encoded(t, record{Age: 3}))
+	if err != nil || len(changes) != 0 {
+		t.Error("expected no changes", changes, err)
+	}
+
+	// top level kinds
+	changes, err = Compat(encoded(t, 1), encoded(t, uint(1)))
+	if err != nil || !Breaking(changes) {
+		t.Error("int to uint should be breaking", changes, err)
+	}
+
+	if _, err := Compat(New(&bytes.Buffer{}), encoded(t, 1)); err == nil {
+		t.Error("an empty stream should error")
+	}
+}
+
+func TestCompatRecordTypes(t *testing.T) {
+	type first struct {
+		Name string
+	}
+	encodedAll := func(vs ...interface{}) *decoder {
+		buf := &bytes.Buffer{}
+		enc := gob.NewEncoder(buf)
+		for _, v := range vs {
+			if err := enc.Encode(v); err != nil {
+				t.Fatal("shame", err)
+			}
+		}
+		return New(buf)
+	}
+	var older, newer *decoder
+	{
+		type second struct {
+			Count string
+		}
+		older = encodedAll(first{"a"}, second{"1"}, first{"b"})
+	}
+	{
+		type second struct {
+			Count int
+		}
+		newer = encodedAll(first{"a"}, second{1})
+	}
+
+	// the second record type is only known once it has been scanned
+	changes, err := Compat(older, newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Change{
+		{Path: "second.Count", Type: TypeChanged, Old: "string", New: "int64", Breaking: true},
+	}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Errorf("got %v", changes)
+	}
+}
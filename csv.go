@@ -0,0 +1,207 @@
+package goblin
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// NestedMode selects how the slices, arrays and maps within records are
+// rendered in CSV cells, which can only hold flat values
+type NestedMode int
+
+const (
+	// NestedJSON renders them as compact json in a single cell
+	NestedJSON NestedMode = iota
+	// NestedLen renders the number of elements they have
+	NestedLen
+	// NestedOmit leaves them out, without a column
+	NestedOmit
+)
+
+// CSVOptions controls how records are written by WriteCSV
+type CSVOptions struct {
+	Comma  rune       // the field delimiter, ',' if 0 and '\t' for TSV
+	Nested NestedMode // how the slices, arrays and maps in records are rendered
+}
+
+// WriteCSV writes the values the decoder has still to scan as CSV, with a
+// header of the column names. Each struct value is a row, as is each element
+// of values that are slices of structs. Nested structs are flattened into
+// columns with dotted names, all in the field order of the type definitions.
+func (d *decoder) WriteCSV(w io.Writer, opts CSVOptions) error {
+	cw := newCSVWriter(w, d, opts)
+	for n := 0; d.Scan(); n++ {
+		if err := cw.write(d.lastVal); err != nil {
+			return fmt.Errorf("value %d: %v", n, err)
+		}
+	}
+	if d.Err() != nil {
+		return d.Err()
+	}
+	return cw.flush()
+}
+
+// WriteCSV writes the value, a struct or slice of structs, as CSV with a
+// header as decoder.WriteCSV does
+func (v Value) WriteCSV(w io.Writer, opts CSVOptions) error {
+	if v.v == nil {
+		return fmt.Errorf("can not write an invalid value")
+	}
+	cw := newCSVWriter(w, v.d, opts)
+	if err := cw.write(v.v); err != nil {
+		return err
+	}
+	return cw.flush()
+}
+
+// column is a struct field flattened into a column
+type column struct {
+	name   string // the dotted names of the field and those it is within
+	path   []int  // the index of the field in each struct on the way to it
+	nested bool   // a slice, array, map or recursive struct, see NestedMode
+}
+
+// csvWriter writes rows of a single struct type
+type csvWriter struct {
+	w    *csv.Writer
+	d    *decoder
+	o    CSVOptions
+	id   typeID   // the struct type of the rows
+	cols []column // made from the first rows type
+	row  []string // reused for each row
+}
+
+func newCSVWriter(w io.Writer, d *decoder, o CSVOptions) *csvWriter {
+	cw := &csvWriter{
+		w: csv.NewWriter(w),
+		d: d,
+		o: o,
+	}
+	if o.Comma != 0 {
+		cw.w.Comma = o.Comma
+	}
+	return cw
+}
+
+// write writes v as rows, it must be a struct or slice of structs of the same
+// type as any written before it
+func (cw *csvWriter) write(v *val) error {
+	id := v.id
+	if v.t == tSlice {
+		id = v.sl.t
+	}
+	if cw.d.wireKind(id) != tStruct || (v.t != tStruct && v.t != tSlice) {
+		return fmt.Errorf("can only write structs or slices of structs as csv not %s", kindOf(v.t, v.sl.array))
+	}
+	if cw.cols == nil {
+		cw.id = id
+		cw.columns(id, "", nil, map[typeID]bool{})
+		names := make([]string, len(cw.cols))
+		for i, c := range cw.cols {
+			names[i] = c.name
+		}
+		if err := cw.w.Write(names); err != nil {
+			return err
+		}
+	}
+	if id != cw.id {
+		return fmt.Errorf("can not write a %s in a table of %s", cw.d.idToType(int(id)), cw.d.idToType(int(cw.id)))
+	}
+
+	if v.t == tStruct {
+		return cw.writeRow(v)
+	}
+	for i := range v.sl.els {
+		if err := cw.writeRow(&v.sl.els[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns adds the fields of the struct type id as columns, descending into
+// nested structs unless they are already being flattened, as recursive types are
+func (cw *csvWriter) columns(id typeID, prefix string, path []int, seen map[typeID]bool) {
+	seen[id] = true
+	_, def, _ := cw.d.wireDef(id)
+	for i, f := range defFields(def) {
+		c := column{
+			name: joinPath(prefix, f.name),
+			path: append(path[:len(path):len(path)], i),
+		}
+		switch k := cw.d.typeKind(f.id); {
+		case k == Struct && !seen[f.id]:
+			cw.columns(f.id, c.name, c.path, seen)
+			continue
+		case k >= Slice:
+			if cw.o.Nested == NestedOmit {
+				continue
+			}
+			c.nested = true
+		}
+		cw.cols = append(cw.cols, c)
+	}
+	delete(seen, id)
+}
+
+func (cw *csvWriter) writeRow(v *val) error {
+	cw.row = cw.row[:0]
+	for _, c := range cw.cols {
+		cw.row = append(cw.row, cw.cell(v, c))
+	}
+	return cw.w.Write(cw.row)
+}
+
+// cell renders the column of the struct v
+func (cw *csvWriter) cell(v *val, c column) string {
+	o := cw.d.opts
+	o.ordered = true
+	present, absent := true, o.absent
+	for _, i := range c.path {
+		f := &v.st[i]
+		if present && !f.nonZero {
+			present, absent = false, f.absentAs(o.absent)
+		}
+		v = &f.v
+	}
+	if !present && absent != AbsentZero {
+		return ""
+	}
+
+	if c.nested {
+		switch {
+		case cw.o.Nested != NestedLen || v.t == tStruct:
+			// recursive structs are always json
+		case v.t == tMap:
+			return strconv.Itoa(len(v.ma.els))
+		default:
+			return strconv.Itoa(len(v.sl.els))
+		}
+		if !present {
+			return compactJSON(v.zero(o))
+		}
+		return compactJSON(v.obj(o))
+	}
+
+	switch v.t {
+	case tBool:
+		return strconv.FormatBool(v.ToBool())
+	case tInt:
+		return strconv.FormatInt(v.ToInt(), 10)
+	case tUint:
+		return strconv.FormatUint(v.ToUint(), 10)
+	case tFloat:
+		return strconv.FormatFloat(v.ToFloat(), 'g', -1, 64)
+	case tBytes:
+		return base64.StdEncoding.EncodeToString(v.da) // as encoding/json does
+	}
+	return string(v.da)
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
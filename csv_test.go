@@ -0,0 +1,93 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+type csvInner struct {
+	Colour uint
+	Label  string
+}
+
+type csvRecord struct {
+	Name  string
+	Age   int
+	Ok    bool
+	Inner csvInner
+	Tags  []string
+	Score float64
+}
+
+func TestWriteCSV(t *testing.T) {
+	recs := []csvRecord{
+		{Name: "goober", Age: 19, Ok: true, Inner: csvInner{Colour: 3, Label: "red, ish"}, Tags: []string{"a", "b"}, Score: 1.5},
+		{Name: "ty"},
+	}
+	stream := func() *decoder {
+		buf := &bytes.Buffer{}
+		enc := gob.NewEncoder(buf)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				t.Fatal("shame", err)
+			}
+		}
+		return New(buf)
+	}
+
+	cases := []struct {
+		name   string
+		opts   CSVOptions
+		absent AbsentMode
+		exp    string
+	}{
+		{
+			name: "json",
+			exp: "Name,Age,Ok,Inner.Colour,Inner.Label,Tags,Score\n" +
+				"goober,19,true,3,\"red, ish\",\"[\"\"a\"\",\"\"b\"\"]\",1.5\n" +
+				"ty,0,false,0,,null,0\n",
+		},
+		{
+			name: "tsv len",
+			opts: CSVOptions{Comma: '\t', Nested: NestedLen},
+			exp: "Name\tAge\tOk\tInner.Colour\tInner.Label\tTags\tScore\n" +
+				"goober\t19\ttrue\t3\tred, ish\t2\t1.5\n" +
+				"ty\t0\tfalse\t0\t\t0\t0\n",
+		},
+		{
+			name:   "omit",
+			opts:   CSVOptions{Nested: NestedOmit},
+			absent: AbsentNull,
+			exp: "Name,Age,Ok,Inner.Colour,Inner.Label,Score\n" +
+				"goober,19,true,3,\"red, ish\",1.5\n" +
+				"ty,,,,,\n",
+		},
+	}
+	for _, c := range cases {
+		d := stream()
+		d.SetAbsent(c.absent)
+		out := &bytes.Buffer{}
+		if err := d.WriteCSV(out, c.opts); err != nil {
+			t.Fatal(c.name, err)
+		}
+		if out.String() != c.exp {
+			t.Errorf("%s: got\n%s", c.name, out)
+		}
+	}
+
+	// a single value that is the whole table
+	d := scanOne(t, recs)
+	out := &bytes.Buffer{}
+	if err := d.Value().WriteCSV(out, CSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != cases[0].exp {
+		t.Errorf("got\n%s", out)
+	}
+
+	d = scanOne(t, []int{1})
+	if err := d.Value().WriteCSV(out, CSVOptions{}); err == nil {
+		t.Error("expected an error for a slice of ints")
+	}
+}
@@ -1,6 +1,8 @@
 package goblin
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,21 +12,52 @@ import (
 
 const uint64Size = 8
 
+// tooBig is the largest message length accepted, as in encoding/gob
+const tooBig = 1 << 30
+
 // decoder does all the hard work decoding a gob file
 type decoder struct {
-	b []byte    // the current buffer of data just read in
-	r io.Reader // the reader to read in chunks of data
-
-	types   map[typeID]val // the type definitions for this decoder
-	lastVal *val           // the last scanned value
-	lastErr error          // errors on the last scan
-
-	level int      // for debugging
-	path  []string // for debugging and pretty errors
+	b      []byte    // the current buffer of data just read in
+	r      io.Reader // the reader to read in chunks of data
+	off    int64     // the number of bytes read from r
+	msgOff int64     // the offset of the message last read into b
+
+	ra io.ReaderAt // for decoders that can Seek, made with NewAt
+	ix *Index      // the index of the messages in ra
+
+	loaded map[int64]bool // the offsets of the type messages NewAt loaded
+
+	types   map[typeID]val    // the type definitions for this decoder
+	schema  Schema            // the type messages the types were decoded from
+	plans   map[typeID]*plan  // the compiled decoders of the types
+	gen     int               // incremented as the types change, for Parallel
+	segment int               // which of the concatenated streams is being read
+	defs    map[typeID][]byte // the message each user type was defined by
+	run     []typeID          // the types defined since the last value
+	runSeg  bool              // whether the run of types started a new stream
+	buf     []byte            // reused to read each message into
+	lastVal *val              // the last scanned value
+	lastErr error             // errors on the last scan
+	opts    objOpts           // how values are rendered by Obj and JSON
+	hints   hints             // original Go types from source, see TypeHints
+
+	zeroCopy bool            // strings and []byte are views into buf, see SetZeroCopy
+	lenient  bool            // skip messages that fail to decode, see SetLenient
+	skipped  []*MessageError // the messages skipped in lenient mode
+	partial  bool            // decode what there is of a truncated last message, see SetPartial
+	trunc    *Truncation     // where a truncated stream ended
+	cut      bool            // the message in b is what there is of a truncated one
+	filter   Filter          // the values Scan returns, see SetFilter
+
+	path []string // for debugging and pretty errors
 }
 
 // New returns a new decoder
 func New(r io.Reader) *decoder {
+	// as encoding/gob does, buffer the reader unless it can already read bytes
+	if _, ok := r.(io.ByteReader); !ok && r != nil {
+		r = bufio.NewReader(r)
+	}
 	d := &decoder{
 		r: r,
 	}
@@ -39,25 +72,136 @@ func (d *decoder) Scan() bool {
 	d.lastVal = nil
 	// if we have not set up yet
 	if len(d.types) == 0 {
-		d.lastErr = d.setupTypes()
-		if d.lastErr != nil {
-			return false
-		}
-		// d.b will be set up for the first data
-	} else {
-		// the second data needs us to set up d.b
-		d.lastErr = d.getBuf()
-		if d.lastErr != nil {
+		d.initTypes()
+	}
+	for {
+		// type definitions come before the first value of each type, or stream
+		if d.lastErr = d.getBuf(); d.lastErr != nil {
 			return false
 		}
 		if len(d.b) == 0 {
 			// this should be the normal end
 			return false
 		}
+		isType, err := d.decodeType()
+		if err == nil && !isType {
+			if err = d.decodeData(); err == nil {
+				if d.filter == nil || d.filter(d.Value()) {
+					return true
+				}
+				d.lastVal = nil
+				continue
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if !d.lenient {
+			d.lastErr = err
+			return false
+		}
+		d.skipped = append(d.skipped, &MessageError{Offset: d.msgOff, Err: err})
 	}
+}
+
+// Segment returns which of the gob streams concatenated in the input the
+// last scanned value came from, counting from 0. Gob never redefines a type
+// id within a stream, so a type definition of an id that is already defined
+// is taken to be the start of the next stream, which starts with the run of
+// type definitions that the redefinition is in. If the definition differs
+// the types are reset to those sent in the run. Streams of only builtin
+// types send no definitions so can not be told apart.
+func (d *decoder) Segment() int {
+	return d.segment
+}
 
-	d.lastErr = d.decodeData()
-	return d.lastErr == nil
+// MessageError is an error decoding the message at Offset in the stream
+type MessageError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *MessageError) Error() string {
+	return fmt.Sprintf("at offset %d: %v", e.Offset, e.Err)
+}
+
+// SetLenient sets whether Scan skips the messages that fail to decode and
+// carries on with the next, rather than stopping, to salvage what it can
+// from corrupt streams. The errors are kept, see Skipped. Messages are found
+// by their length prefix so a bad prefix, or a truncated stream, still stops
+// the Scan.
+func (d *decoder) SetLenient(on bool) {
+	d.lenient = on
+}
+
+// Skipped returns the errors of the messages that Scan has skipped in lenient mode
+func (d *decoder) Skipped() []*MessageError {
+	return d.skipped
+}
+
+// Truncation is where a stream that was cut short ended, see SetPartial
+type Truncation struct {
+	Offset int64 // the byte offset where the data ended
+	// Incomplete are the dotted paths in the last value that were cut short,
+	// outermost first. The value itself is always incomplete.
+	Incomplete []string
+}
+
+// SetPartial sets whether a stream that ends part way through a message, as
+// a crashed writer leaves it, has as much of that last value decoded as there
+// is, rather than Scan failing. The fields that were not reached are absent.
+// See Truncated for where the stream ended and which values are incomplete.
+func (d *decoder) SetPartial(on bool) {
+	d.partial = on
+}
+
+// Truncated returns where the stream was cut short, nil if it was not, or
+// the Scan has not reached the end yet. SetPartial must be on.
+func (d *decoder) Truncated() *Truncation {
+	return d.trunc
+}
+
+// cutShort records the path of a value that ran out of data, if it is the
+// first, which is the deepest, so far
+func (d *decoder) cutShort(path []string) {
+	if !d.cut || d.trunc.Incomplete != nil {
+		return
+	}
+	d.trunc.Incomplete = []string{}
+	for i := range path {
+		d.trunc.Incomplete = append(d.trunc.Incomplete, strings.Join(path[:i+1], "."))
+	}
+}
+
+// Skip moves past the next value without decoding it, so the next Scan
+// decodes the one after. The value message is found and read by its length
+// alone, so it is skipped whether or not it would pass the filter, see
+// SetFilter. Any type definitions before it are decoded, as later values may
+// need them, and start a new Segment as they do for Scan. Like Scan it
+// returns false at the end of the stream or on an error.
+func (d *decoder) Skip() bool {
+	d.lastErr = nil
+	d.lastVal = nil
+	if len(d.types) == 0 {
+		d.initTypes()
+	}
+	for {
+		if d.lastErr = d.getBuf(); d.lastErr != nil || len(d.b) == 0 {
+			return false
+		}
+		isType, err := d.decodeType()
+		if err == nil && !isType {
+			return true
+		}
+		if err == nil {
+			continue
+		}
+		if !d.lenient {
+			d.lastErr = err
+			return false
+		}
+		d.skipped = append(d.skipped, &MessageError{Offset: d.msgOff, Err: err})
+	}
 }
 
 // Err returns any errors from the last call to Scan
@@ -65,13 +209,40 @@ func (d *decoder) Err() error {
 	return d.lastErr
 }
 
+// SetAbsent sets how struct fields that were not present in the gob data
+// are rendered by Obj and JSON. The default is AbsentZero.
+func (d *decoder) SetAbsent(m AbsentMode) {
+	d.opts.absent = m
+}
+
+// SetBigIntStrings sets whether integers too big to be exactly represented
+// by a float64 (beyond 2^53) are rendered by Obj and JSON as strings, so that
+// JavaScript consumers do not lose precision.
+func (d *decoder) SetBigIntStrings(on bool) {
+	d.opts.bigInts = on
+}
+
+// SetNonFinite sets how NaN and infinite floats are rendered by Obj and JSON.
+// The default NonFiniteError leaves them as floats, which JSON can not marshal.
+func (d *decoder) SetNonFinite(m NonFiniteMode) {
+	d.opts.nonFinite = m
+}
+
+// SetZeroCopy sets whether decoded strings and []byte refer directly to the
+// buffer the message was read into, rather than being copied out of it. This
+// saves an allocation for each one, but as the buffer is reused, the values
+// from a Scan are then only valid until the next call to Scan.
+func (d *decoder) SetZeroCopy(on bool) {
+	d.zeroCopy = on
+}
+
 // Obj returns the result of the last Scan as a limited object where any map keys are
 // represented as strings, making the object json compatible.
 func (d *decoder) Obj() interface{} {
 	if d.lastVal == nil {
 		return nil
 	}
-	return d.lastVal.obj()
+	return d.lastVal.obj(d.opts)
 }
 
 // Json returns the result of a call to Obj marshalled as an indented JSON []byte
@@ -79,15 +250,26 @@ func (d *decoder) JSON() ([]byte, error) {
 	if d.lastVal == nil {
 		return nil, errors.New("can not return json representation of none existant object")
 	}
-	return json.MarshalIndent(d.lastVal.obj(), "", "  ")
+	return json.MarshalIndent(d.lastVal.obj(d.opts), "", "  ")
+}
+
+// OrderedJSON returns the result of the last Scan marshalled as indented JSON
+// with struct fields in the order they are declared in the gob type, rather
+// than the alphabetical order of JSON.
+func (d *decoder) OrderedJSON() ([]byte, error) {
+	if d.lastVal == nil {
+		return nil, errors.New("can not return json representation of none existant object")
+	}
+	o := d.opts
+	o.ordered = true
+	return json.MarshalIndent(d.lastVal.obj(o), "", "  ")
 }
 
 // WriteTypes dumps to the given writer the representation of the type information
 // in a golang struct compatible way
 func (d *decoder) WriteTypes(w io.Writer) {
-	for k, v := range d.types {
-		if k > minUserType {
-			d.toType(w, &v)
+	for k := range d.types {
+		if k > minUserType && d.toType(w, k) {
 			fmt.Fprintln(w)
 		}
 	}
@@ -107,45 +289,123 @@ func (d *decoder) decodeTypes() error {
 		if err != nil {
 			return err
 		}
-		start := d.b
-
-		// decode the length - though we don't actually use it in here
-		_, err = d.decodeUint()
-		if err != nil {
-			return err
+		if len(d.b) == 0 {
+			return nil // there were no values
 		}
-		// get the negative type ID
-		typ, err := d.decodeInt()
+		isType, err := d.decodeType()
 		if err != nil {
 			return err
 		}
-		// if the type id is > 0 then it is the actual value data
-		if typ > 0 {
-			d.b = start // restore the last three bytes that are the start of a value
+		if !isType {
 			return nil
 		}
-		ty := val{}
+	}
+}
 
-		ty.copy(d.types[tWireType])
-		nt, err := d.start(ty)
-		if err != nil {
-			return err
+// decodeType decodes the wireType message in d.b adding it to the types
+// index. If the message is not a type definition d.b is left untouched.
+func (d *decoder) decodeType() (bool, error) {
+	if d.loaded[d.msgOff] {
+		return true, nil // already loaded by NewAt
+	}
+	start := d.b
+
+	if err := d.msgLen(); err != nil {
+		return false, err
+	}
+	// get the negative type ID
+	typ, err := d.decodeInt()
+	if err != nil {
+		return false, err
+	}
+	// if the type id is > 0 then it is the actual value data
+	if typ > 0 {
+		d.b = start // restore the last three bytes that are the start of a value
+		d.endRun()
+		return false, nil
+	}
+	p, err := d.plan(tWireType)
+	if err != nil {
+		return false, err
+	}
+	var nt val
+	// the names in a type are kept, so must not refer to the buffer
+	zeroCopy := d.zeroCopy
+	d.zeroCopy = false
+	err = p.run(d, &nt)
+	d.zeroCopy = zeroCopy
+	if err != nil {
+		return false, err
+	}
+	if err := d.msgEnd(); err != nil {
+		return false, err
+	}
+	id := -typeID(typ)
+	if def, ok := d.defs[id]; ok {
+		if !d.runSeg {
+			// a new stream has started
+			d.segment++
+			d.runSeg = true
+		}
+		if bytes.Equal(def, start) {
+			// sent again as it was, so the types stand
+			d.run = append(d.run, id)
+			return true, nil
 		}
-		// add it to the index of types
-		d.types[-typeID(typ)] = nt
+		d.resetTypes(id)
+	}
+	if d.defs == nil {
+		d.defs = map[typeID][]byte{}
+	}
+	// add it to the index of types
+	d.types[id] = nt
+	d.defs[id] = append([]byte(nil), start...)
+	d.schema = append(d.schema, start...)
+	d.run = append(d.run, id)
+	d.plans = nil // plans may have been made before all their types were known
+	return true, nil
+}
+
+// resetTypes starts the types of a new stream that redefines id, keeping
+// those defined in the run before it, which gob sent for the same value
+func (d *decoder) resetTypes(id typeID) {
+	types, defs, run := d.types, d.defs, d.run
+	d.initTypes()
+	d.defs = map[typeID][]byte{}
+	d.schema = nil
+	d.run = nil
+	d.gen++
+	for _, k := range run {
+		if k == id {
+			// the run spans both streams, so where the new one starts is not known
+			return
+		}
+	}
+	for _, k := range run {
+		if _, ok := d.defs[k]; ok {
+			continue // sent twice
+		}
+		d.types[k] = types[k]
+		d.defs[k] = defs[k]
+		d.schema = append(d.schema, defs[k]...)
+		d.run = append(d.run, k)
 	}
 }
 
+// endRun marks a value message, after which any type definitions are a new run
+func (d *decoder) endRun() {
+	d.run = d.run[:0]
+	d.runSeg = false
+}
+
 // decodeTypes loads in the wireTypes from the gob types section
 func (d *decoder) decodeData() error {
 	// if we have used up all the bytes then there is no more data
 	if len(d.b) == 0 {
 		return nil
 	}
-	// decode the length - though we don't actually use it
-	_, err := d.decodeUint()
-	if err != nil {
-		return err // TODO - richer nested errors
+	if err := d.msgLen(); err != nil {
+		return err
 	}
 	// get the type ID
 	typ, err := d.decodeInt()
@@ -153,24 +413,18 @@ func (d *decoder) decodeData() error {
 		return err
 	}
 
-	var data val
 	tid := typeID(typ)
-	// top level primitive types
-	if tid < minUserType {
-		data = val{
-			t: tid,
-		}
-	} else {
-		typeDesc, ok := d.types[tid]
-		if !ok {
-			return fmt.Errorf("got type index entry %d that does not exist", tid)
-		}
-		// get the initial type
-		data = d.fromWireType(typeDesc)
+	if _, ok := d.types[tid]; !ok && tid >= minUserType {
+		return fmt.Errorf("got type index entry %d that does not exist", tid)
+	}
+	// get the compiled decoder for the type
+	p, err := d.plan(tid)
+	if err != nil {
+		return err
 	}
 
 	// is it a top level type that has a field delta of 0
-	if data.t != tStruct {
+	if p.t != tStruct {
 		//then read in the zero delta (wonder why this exists?)
 		_, err = d.decodeUint()
 		if err != nil {
@@ -178,200 +432,124 @@ func (d *decoder) decodeData() error {
 		}
 	}
 
-	data, err = d.start(data)
-	if err != nil {
-		return err
-	}
-	d.lastVal = &data
-
-	return nil
-}
-
-func (d decoder) paths() string {
-	return strings.Join(d.path, ".")
-}
-
-func (d *decoder) start(v val) (val, error) {
-	// copy behaves as deep copy as val has no references
-	d.level = -1
-	x := v
-	err := d.decode(&x)
-	return x, err
-}
-
-func (d *decoder) decode(x *val) error {
-	switch x.t {
-	case tBool:
-		b := d.decodeBool()
-		if b {
-			x.da = []byte{1}
-		} else {
-			x.da = []byte{0}
-		}
-		return nil
-	case tInt, tUint, tFloat:
-		iv, err := d.decodeUint()
-		if err != nil {
+	data := &val{}
+	d.path = d.path[:0]
+	if err := p.run(d, data); err != nil {
+		if !d.cut {
 			return err
 		}
-		x.nu = iv
-		return nil
-	case tBytes, tString:
-		return d.decodeBytes(x)
-	case tSlice:
-		err := d.decodeSlice(x)
+		// keep what there is of the last value
+		d.cutShort(nil)
+	} else if err := d.msgEnd(); err != nil {
 		return err
-	case tMap:
-		return d.decodeMap(x)
-	case tStruct:
-		return d.decodeStruct(x)
-	}
-	// dereference the indexed type up and add a copy to the val
-	t, ok := d.types[x.t]
-	if !ok {
-		return fmt.Errorf("%q found type id that is not in index: %d", d.paths(), x.t)
-	}
-	// the wiretypes are already expanded
-	if x.t < minUserType {
-		x.copy(t)
-	} else {
-		// for other dynamic types
-		dt := d.fromWireType(t)
-		x.copy(dt)
 	}
-	// and decode it
-	return d.decode(x)
-}
+	d.lastVal = data
 
-func (d *decoder) decodeMap(v *val) error {
-	d.path = append(d.path, "")
-	d.level++
-	defer func() {
-		d.level--
-		d.path = d.path[:len(d.path)-1]
-	}()
+	return nil
+}
 
-	// get element count
-	ui, err := d.decodeUint()
+// msgLen reads the length prefix of the message in d.b, which must be the
+// length of the rest of it, so decoding is bounded by the message
+func (d *decoder) msgLen() error {
+	l, err := d.decodeUint()
 	if err != nil {
 		return err
 	}
-	v.ma.els = map[string]val{}
-	for i := 0; i < int(ui); i++ {
-		k := val{
-			t: v.ma.kt,
-		}
-		err := d.decode(&k)
-		if err != nil {
-			return err
-		}
-		nv := val{
-			t: v.ma.vt,
-		}
-		err = d.decode(&nv)
-		if err != nil {
-			return err
-		}
-		// all keys take their standard string representation
-		v.ma.els[fmt.Sprintf("%v", k.obj())] = nv
+	if l != uint64(len(d.b)) && !d.cut {
+		return fmt.Errorf("the message length %d does not match its %d bytes", l, len(d.b))
 	}
-
 	return nil
 }
 
-func (d *decoder) decodeStruct(x *val) error {
-	fc := -1
-	d.path = append(d.path, "")
-	d.level++
-	defer func() {
-		d.level--
-		d.path = d.path[:len(d.path)-1]
-	}()
-	for {
-		// get the field delta
-		delta, err := d.decodeUint()
-		if err != nil {
-			return err
-		}
-		if delta == 0 { // end of fields with the 0 delta terminator
-			return nil
-		}
-		fc += int(delta)
-		if fc >= len(x.st) {
-			return fmt.Errorf("%s bad encoding more fields than the type len: %d expected: %d ", d.paths(), fc, len(x.st))
-		}
-
-		d.path[d.level] = x.st[fc].name
-		x.st[fc].nonZero = true
-		err = d.decode(&x.st[fc].v)
-		if err != nil {
-			return err
-		}
+// msgEnd checks that decoding the message used all of it
+func (d *decoder) msgEnd() error {
+	if len(d.b) > 0 {
+		return fmt.Errorf("%d bytes left over at the end of the message", len(d.b))
 	}
+	return nil
 }
 
-func (d *decoder) decodeBytes(v *val) error {
-	len, err := d.decodeUint()
-	if err != nil {
-		return err
-	}
-	v.da = make([]byte, len)
-	copy(v.da, d.b[:len])
-	d.b = d.b[len:]
-	return nil
+func (d decoder) paths() string {
+	return strings.Join(d.path, ".")
 }
 
-func (d *decoder) decodeSlice(v *val) error {
-	ui, err := d.decodeUint()
+func (d *decoder) decodeBytes(v *val) error {
+	n, err := d.decodeUint()
 	if err != nil {
 		return err
 	}
-	len := int(ui)
-	v.sl.els = make([]val, len)
-	for i := 0; i < len; i++ {
-		// give each elem the predefined type for this slice
-		v.sl.els[i].t = v.sl.t
-		// and decode each one
-		if err := d.decode(&(v.sl.els[i])); err != nil {
-			return err
-		}
+	if n > uint64(len(d.b)) {
+		return fmt.Errorf("%s length %d is beyond the end of the message", d.paths(), n)
 	}
+	if d.zeroCopy {
+		v.da = d.b[:n:n]
+	} else {
+		v.da = make([]byte, n)
+		copy(v.da, d.b[:n])
+	}
+	d.b = d.b[n:]
 	return nil
 }
 
+// getBuf reads the next whole message, including its length prefix, into d.b
 func (d *decoder) getBuf() error {
-	buf := make([]byte, uint64Size)
-	n, err := d.r.Read(buf)
-	if n == 0 {
+	d.cut = false
+	if d.r == nil {
 		d.b = nil
-		return nil
+		return errors.New("there is no stream to read, see DecodeMessage")
 	}
-	if err != nil && err != io.EOF {
+	// read the length prefix a byte at a time so as not to read into the next message
+	var prefix [uint64Size + 1]byte
+	n, err := io.ReadFull(d.r, prefix[:1])
+	if n == 0 {
+		d.b = nil
+		if err == io.EOF {
+			return nil // the normal end
+		}
 		return err
 	}
-
-	l, v, err := decodeUint(buf)
+	l := 1
+	if prefix[0] > 0x7f {
+		l += -int(int8(prefix[0]))
+		if l > len(prefix) {
+			return errors.New("bad unit size")
+		}
+		if n, err := io.ReadFull(d.r, prefix[1:l]); err != nil {
+			if d.partial && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				d.trunc = &Truncation{Offset: d.off + 1 + int64(n)}
+				d.b = nil
+				return nil
+			}
+			return fmt.Errorf("could not read the message length: %v", err)
+		}
+	}
+	_, v, err := decodeUint(prefix[:l])
 	if err != nil {
 		return err
 	}
-	// make enough for the whole block
-	blockLen := l + int(v)
-	d.b = make([]byte, blockLen)
-	// work out how much more we actually need to read
-	// taking off what we already read
-	toRead := blockLen - n
+	if v > tooBig || int(v) < 0 {
+		return fmt.Errorf("message length %d is too big", v)
+	}
 
-	// copy over what we have read already
-	for i := 0; i < n; i++ {
-		d.b[i] = buf[i]
+	// make enough for the whole block and copy over the length we have read already
+	need := l + int(v)
+	if cap(d.buf) < need {
+		d.buf = make([]byte, need)
 	}
+	d.b = d.buf[:need]
+	copy(d.b, prefix[:l])
 	// fill the bit we have not read
-	buf = d.b[n:]
-	n, err = d.r.Read(buf)
-	if n != toRead {
-		return fmt.Errorf("could not read the required number (%d) of bytes, only read (%d)", toRead, n)
-	}
-	return err
+	n, err = io.ReadFull(d.r, d.b[l:])
+	if d.partial && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+		d.b = d.b[:l+n]
+		d.trunc = &Truncation{Offset: d.off + int64(l+n)}
+		d.cut = true
+	} else if err != nil {
+		return fmt.Errorf("could not read the required number (%d) of bytes, only read (%d)", v, n)
+	}
+	d.msgOff = d.off
+	d.off += int64(len(d.b))
+	return nil
 }
 
 // decodeUint reads an encoded unsigned integer from b
@@ -389,6 +567,9 @@ func (d *decoder) decodeUint() (x uint64, err error) {
 }
 
 func decodeUint(buf []byte) (l int, x uint64, err error) {
+	if len(buf) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
 	b := buf[0]
 	if b <= 0x7f {
 		return 1, uint64(b), nil
@@ -2,7 +2,10 @@ package goblin
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
+	"encoding/json"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -162,10 +165,6 @@ var expected2 = []byte(`{
 }`)
 
 var expectedTypes = `
-type Type136 [3]int64	//[3]int
-
-type Type132 []int64	//[]int
-
 type other struct {
   Colour uint64
 }
@@ -175,11 +174,11 @@ type bart struct {
   Age int64
   Pimples int64
   Sane bool
-  Lengths []int
+  Lengths []int64
   Other other
   Height float64
   Blob []byte
-  Sizes [3]int
+  Sizes [3]int64
 }`
 
 // sameLines compares l and r line by line for matching sorted lines
@@ -292,6 +291,66 @@ var expMaps = `{
   }
 }`
 
+type point struct {
+	X, Y int
+}
+
+type shapes struct {
+	ByName  map[string]point
+	ByPoint map[point]int
+	Nested  []map[string][]point
+	Ptrs    []*point
+	ByPair  map[[2]int]string
+}
+
+func TestNestedShapes(t *testing.T) {
+	in := shapes{
+		ByName:  map[string]point{"a": {1, 2}},
+		ByPoint: map[point]int{{1, 2}: 3, {0, 5}: 4},
+		Nested:  []map[string][]point{{"x": {{1, 1}, {2, 2}}}, {}},
+		Ptrs:    []*point{{3, 4}, {5, 6}},
+		ByPair:  map[[2]int]string{{1, 2}: "a"},
+	}
+	d := scanOne(t, in)
+
+	b, err := d.OrderedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"ByName":{"a":{"X":1,"Y":2}},` +
+		`"ByPoint":{"{\"X\":0,\"Y\":5}":4,"{\"X\":1,\"Y\":2}":3},` +
+		`"Nested":[{"x":[{"X":1,"Y":1},{"X":2,"Y":2}]},{}],` +
+		`"Ptrs":[{"X":3,"Y":4},{"X":5,"Y":6}],` +
+		`"ByPair":{"[1,2]":"a"}}`
+	if got := compact(t, b); got != exp {
+		t.Error("bad nested json", got)
+	}
+
+	v, _ := d.Value().Field("ByPoint")
+	ks := v.MapKeys()
+	if len(ks) != 2 {
+		t.Fatal("wrong key count", len(ks))
+	}
+	if x, _ := ks[1].Field("X"); x.ToInt() != 1 {
+		t.Error("wrong key field", x.ToInt())
+	}
+	if e, ok := v.MapIndex(`{"X":1,"Y":2}`); !ok || e.ToInt() != 3 {
+		t.Error("missing struct key")
+	}
+
+	var out shapes
+	r, err := d.Into(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() {
+		t.Errorf("bad report %+v", r)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %+v", out)
+	}
+}
+
 func TestRootObjMap(t *testing.T) {
 	m := map[int]string{
 		25: "zero",
@@ -315,7 +374,8 @@ func TestRootObjMap(t *testing.T) {
 
 	buf = &bytes.Buffer{}
 	d.WriteTypes(buf)
-	if buf.String() != "type Type148 map[int64]string\n\n" {
+	// an unnamed map has no type to declare
+	if buf.String() != "" {
 		t.Errorf("not expected map types: %q", buf.String())
 	}
 
@@ -446,3 +506,500 @@ func TestDecodeBool(t *testing.T) {
 		}
 	}
 }
+
+func TestConcatenatedStreams(t *testing.T) {
+	type first struct {
+		Name string
+	}
+	type second struct {
+		Size  float64
+		Count int
+	}
+
+	// gob type ids are given out per process, so the second stream redefines
+	// the ids of the first by sending the same type again
+	buf := &bytes.Buffer{}
+	for _, vs := range [][]interface{}{
+		{first{Name: "a"}, second{Size: 1.5}}, // second's type is sent mid stream
+		{first{Name: "b"}, first{Name: "c"}},
+	} {
+		enc := gob.NewEncoder(buf)
+		for _, v := range vs {
+			if err := enc.Encode(v); err != nil {
+				t.Fatal("shame", err)
+			}
+		}
+	}
+	stream := buf.Bytes()
+
+	d := New(bytes.NewReader(stream))
+	var got []string
+	for d.Scan() {
+		b, err := json.Marshal(d.Obj())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strconv.Itoa(d.Segment())+" "+string(b))
+	}
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	exp := []string{
+		`0 {"Name":"a"}`,
+		`0 {"Count":0,"Size":1.5}`,
+		`1 {"Name":"b"}`,
+		`1 {"Name":"c"}`,
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %q", got)
+	}
+
+	d = New(bytes.NewReader(stream))
+	var segs []int
+	for r := range d.Parallel(2, nil, nil) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		segs = append(segs, r.Segment)
+	}
+	if !reflect.DeepEqual(segs, []int{0, 0, 1, 1}) {
+		t.Errorf("got parallel segments %v", segs)
+	}
+}
+
+func TestConcatenatedNewOuterType(t *testing.T) {
+	type inner struct {
+		N int
+	}
+	type outer struct {
+		In inner
+		S  string
+	}
+
+	// the second stream sends the new id of outer before inner again
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(inner{N: 1}); err != nil {
+		t.Fatal("shame", err)
+	}
+	if err := gob.NewEncoder(buf).Encode(outer{In: inner{N: 2}, S: "x"}); err != nil {
+		t.Fatal("shame", err)
+	}
+
+	d := New(bytes.NewReader(buf.Bytes()))
+	var got []string
+	for d.Scan() {
+		b, err := json.Marshal(d.Obj())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strconv.Itoa(d.Segment())+" "+string(b))
+	}
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	exp := []string{
+		`0 {"N":1}`,
+		`1 {"In":{"N":2},"S":"x"}`,
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %q", got)
+	}
+
+	d = New(bytes.NewReader(buf.Bytes()))
+	var segs []int
+	for r := range d.Parallel(2, nil, nil) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		segs = append(segs, r.Segment)
+	}
+	if !reflect.DeepEqual(segs, []int{0, 1}) {
+		t.Errorf("got parallel segments %v", segs)
+	}
+}
+
+// redefinedStreams returns a stream of a, and one of b as if written by another
+// process, where the last type b defines was given the id of the first of a
+func redefinedStreams(t *testing.T, a, b interface{}) ([]byte, []byte) {
+	var streams [2][]byte
+	for i, v := range []interface{}{a, b} {
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(v); err != nil {
+			t.Fatal("shame", err)
+		}
+		streams[i] = buf.Bytes()
+	}
+	ida, err := msgType(streams[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs [][]byte
+	var idb int64
+	for rest := streams[1]; len(rest) > 0; {
+		n, l, err := decodeUint(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := rest[n : n+int(l)]
+		rest = rest[n+int(l):]
+		if typ, _ := msgType(append([]byte{0}, msg...)); typ < 0 {
+			idb = typ
+		}
+		msgs = append(msgs, msg)
+	}
+
+	gobUint := func(x int64) []byte {
+		var b [uint64Size]byte
+		binary.BigEndian.PutUint64(b[:], uint64(x))
+		i := 0
+		for i < len(b)-1 && b[i] == 0 {
+			i++
+		}
+		if i == len(b)-1 && b[i] < 0x80 {
+			return b[i:]
+		}
+		return append([]byte{byte(-(len(b) - i))}, b[i:]...)
+	}
+	// ids are zig zag encoded, the definition's negative and the others positive
+	streams[1] = nil
+	for _, msg := range msgs {
+		msg = bytes.Replace(msg, gobUint(-2*idb-1), gobUint(-2*ida-1), -1)
+		msg = bytes.Replace(msg, gobUint(-2*idb), gobUint(-2*ida), -1)
+		streams[1] = append(append(streams[1], gobUint(int64(len(msg)))...), msg...)
+	}
+	return streams[0], streams[1]
+}
+
+func TestConcatenatedRedefined(t *testing.T) {
+	type first struct {
+		N int
+	}
+	type second struct {
+		S string
+	}
+	type outer struct {
+		In second
+	}
+	// outer's new id is sent before the redefinition of first's id as second
+	a, b := redefinedStreams(t, first{N: 1}, outer{In: second{S: "x"}})
+	d := New(bytes.NewReader(append(a, b...)))
+	var got []string
+	for d.Scan() {
+		b, err := json.Marshal(d.Obj())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strconv.Itoa(d.Segment())+" "+string(b))
+	}
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	if exp := []string{`0 {"N":1}`, `1 {"In":{"S":"x"}}`}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestLenient(t *testing.T) {
+	type rec struct {
+		Name string
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for _, n := range []string{"a", "b", "c", "d"} {
+		if err := enc.Encode(rec{Name: n}); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+	ix, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each value is the length, two byte type id, field delta, string length and name
+	b, c := ix.Values[1], ix.Values[2]
+	stream[b+3] = 0x7f // a field beyond the struct
+	stream[c+4] = 0x7f // a string beyond the message
+
+	d := New(bytes.NewReader(stream))
+	if d.Scan(); !d.Scan() && d.Err() == nil {
+		t.Error("expected an error on the corrupt message")
+	}
+
+	d = New(bytes.NewReader(stream))
+	d.SetLenient(true)
+	var got []string
+	for d.Scan() {
+		got = append(got, d.Obj().(map[string]interface{})["Name"].(string))
+	}
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	if !reflect.DeepEqual(got, []string{"a", "d"}) {
+		t.Errorf("got %q", got)
+	}
+	sk := d.Skipped()
+	if len(sk) != 2 || sk[0].Offset != b || sk[1].Offset != c {
+		t.Fatalf("got skipped %v", sk)
+	}
+	if exp := "at offset " + strconv.FormatInt(b, 10) + ": "; !strings.HasPrefix(sk[0].Error(), exp) {
+		t.Errorf("got error %q", sk[0])
+	}
+
+	// a length prefix that is negative as an int, or too big, can not be skipped
+	for _, prefix := range [][]byte{
+		{0xf8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf7},
+		{0xfc, 0x7f, 0xff, 0xff, 0xff},
+	} {
+		d = New(bytes.NewReader(append(stream[:len(stream):len(stream)], prefix...)))
+		d.SetLenient(true)
+		n := 0
+		for d.Scan() {
+			n++
+		}
+		if n != 2 || d.Err() == nil {
+			t.Errorf("% x: scanned %d values with error %v", prefix, n, d.Err())
+		}
+	}
+}
+
+func TestPartial(t *testing.T) {
+	type inner struct {
+		Label string
+		Size  int
+	}
+	type rec struct {
+		Name  string
+		Inner inner
+		Tags  map[string]inner
+		Count int
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for _, n := range []string{"a", "b"} {
+		r := rec{Name: n, Inner: inner{Label: "long label", Size: 3}, Tags: map[string]inner{"x": {Label: "y"}}, Count: 1}
+		if err := enc.Encode(r); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+	ix, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		end        int64 // where the stream is cut
+		exp        string
+		incomplete []string
+	}{
+		{
+			end:        ix.Values[1] + 10, // part way through the label
+			exp:        `{"Count":0,"Inner":{"Label":"","Size":0},"Name":"b","Tags":null}`,
+			incomplete: []string{"Inner", "Inner.Label"},
+		},
+		{
+			end:        int64(len(stream)) - 5, // in the map element's label
+			exp:        `{"Count":0,"Inner":{"Label":"long label","Size":3},"Name":"b","Tags":{"x":{"Label":"","Size":0}}}`,
+			incomplete: []string{"Tags", "Tags.x", "Tags.x.Label"},
+		},
+		{
+			end:        int64(len(stream)) - 1, // the end of the fields
+			exp:        `{"Count":1,"Inner":{"Label":"long label","Size":3},"Name":"b","Tags":{"x":{"Label":"y","Size":0}}}`,
+			incomplete: []string{},
+		},
+	}
+	for _, c := range cases {
+		cut := stream[:c.end]
+		d := New(bytes.NewReader(cut))
+		if d.Scan(); d.Scan() || d.Err() == nil {
+			t.Errorf("%d: expected an error without SetPartial", c.end)
+		}
+
+		d = New(bytes.NewReader(cut))
+		d.SetPartial(true)
+		var got []string
+		for d.Scan() {
+			b, err := json.Marshal(d.Obj())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, string(b))
+		}
+		if d.Err() != nil {
+			t.Fatal(d.Err())
+		}
+		if len(got) != 2 || got[1] != c.exp {
+			t.Errorf("%d: got %q", c.end, got)
+		}
+		tr := d.Truncated()
+		if tr == nil {
+			t.Fatalf("%d: not truncated", c.end)
+		}
+		if tr.Offset != c.end || !reflect.DeepEqual(tr.Incomplete, c.incomplete) {
+			t.Errorf("%d: got truncation %d %q", c.end, tr.Offset, tr.Incomplete)
+		}
+	}
+
+	d := New(bytes.NewReader(stream))
+	d.SetPartial(true)
+	for d.Scan() {
+	}
+	if d.Err() != nil || d.Truncated() != nil {
+		t.Error("the whole stream is not truncated", d.Err())
+	}
+}
+
+func TestPartialSlice(t *testing.T) {
+	type rec struct {
+		Name string
+		Nums []int
+		Tags map[int]int
+	}
+	encode := func(r rec) []byte {
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(r); err != nil {
+			t.Fatal("shame", err)
+		}
+		return buf.Bytes()
+	}
+	scan := func(stream []byte) *decoder {
+		d := New(bytes.NewReader(stream))
+		d.SetPartial(true)
+		if !d.Scan() {
+			t.Fatal(d.Err())
+		}
+		if d.Truncated() == nil {
+			t.Fatal("not truncated")
+		}
+		return d
+	}
+
+	// the last two elements and the terminator are cut off, so there are
+	// fewer bytes than elements
+	stream := encode(rec{Name: "a", Nums: []int{1, 2, 3, 4, 5, 6}})
+	d := scan(stream[:len(stream)-3])
+	b, err := json.Marshal(d.Obj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Name":"a","Nums":[1,2,3,4,0],"Tags":null}` {
+		t.Errorf("got %s", b)
+	}
+	if inc := d.Truncated().Incomplete; !reflect.DeepEqual(inc, []string{"Nums", "Nums.4"}) {
+		t.Errorf("got incomplete %q", inc)
+	}
+
+	// only the first key is left, in whichever order the map was sent
+	stream = encode(rec{Name: "a", Tags: map[int]int{1: 1, 2: 2}})
+	d = scan(stream[:len(stream)-4])
+	tags, _ := d.Value().Field("Tags")
+	if tags.Len() != 1 {
+		t.Errorf("got %d map elements", tags.Len())
+	}
+	if inc := d.Truncated().Incomplete; len(inc) != 2 || inc[0] != "Tags" {
+		t.Errorf("got incomplete %q", inc)
+	}
+}
+
+func TestMessageLength(t *testing.T) {
+	type rec struct {
+		Name string
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for _, n := range []string{"a", "b", "c"} {
+		if err := enc.Encode(rec{Name: n}); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+
+	// skipping reads the types but not the values
+	d := New(bytes.NewReader(stream))
+	var got []string
+	for d.Skip() && d.Scan() {
+		got = append(got, d.Obj().(map[string]interface{})["Name"].(string))
+	}
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	if !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("got %q", got)
+	}
+
+	// a byte after the value that its length includes
+	ix, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := ix.Values[2]
+	bad := append(append([]byte{}, stream...), 0)
+	bad[last]++
+	d = New(bytes.NewReader(bad))
+	for d.Scan() {
+	}
+	if d.Err() == nil || d.Err().Error() != "1 bytes left over at the end of the message" {
+		t.Errorf("got %v", d.Err())
+	}
+
+	d = New(bytes.NewReader(stream))
+	d.Scan()
+	if err := d.DecodeMessage(bad[last:]); err == nil {
+		t.Error("expected an error for the bytes left over")
+	}
+	if err := d.DecodeMessage(stream[last : len(stream)-1]); err == nil {
+		t.Error("expected an error for the short message")
+	}
+
+	// only the truncated message itself is let off its length
+	d = New(bytes.NewReader(stream[:len(stream)-1]))
+	d.SetPartial(true)
+	for d.Scan() {
+	}
+	if d.Err() != nil || d.Truncated() == nil {
+		t.Fatal("expected a truncated stream", d.Err())
+	}
+	if err := d.DecodeMessage(stream[last : len(stream)-1]); err == nil {
+		t.Error("expected an error for the short message after the truncation")
+	}
+}
+
+func TestZeroCopyTypeNames(t *testing.T) {
+	type Record struct {
+		Name  string
+		Count int
+		Label string
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < 2; i++ {
+		if err := enc.Encode(Record{"a", 1, "b"}); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+
+	d := New(bytes.NewReader(buf.Bytes()))
+	d.SetZeroCopy(true)
+	n := 0
+	for d.Scan() {
+		n++
+		// the buffer has been reused for the values, so the names must be copies
+		if got := d.Value().TypeName(); got != "Record" {
+			t.Errorf("%d) got type name %q", n, got)
+		}
+		if got := d.Value().GoString(); !strings.HasPrefix(got, "Record{") {
+			t.Errorf("%d) got literal %s", n, got)
+		}
+	}
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	if n != 2 {
+		t.Fatalf("scanned %d values", n)
+	}
+	types := &strings.Builder{}
+	d.WriteTypes(types)
+	if !strings.Contains(types.String(), "type Record struct") {
+		t.Errorf("got types %s", types)
+	}
+}
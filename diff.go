@@ -0,0 +1,181 @@
+package goblin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// DeltaOp is the kind of difference between two values
+type DeltaOp int
+
+const (
+	// Changed values are in both but differ
+	Changed DeltaOp = iota
+	// Added values are only in the second, as struct fields, slice elements or map keys
+	Added
+	// Removed values are only in the first
+	Removed
+)
+
+var deltaSigns = []string{
+	Changed: "~",
+	Added:   "+",
+	Removed: "-",
+}
+
+// Delta is a single difference found by Diff
+type Delta struct {
+	Path string      // the dotted path to the value, with [i] for elements and [key] for map keys
+	Op   DeltaOp     // what happened to the value
+	Old  interface{} // the first value as an Obj, nil if added
+	New  interface{} // the second value as an Obj, nil if removed
+}
+
+func (dl Delta) String() string {
+	path := dl.Path
+	if path == "" {
+		path = "."
+	}
+	switch dl.Op {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", path, compactJSON(dl.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", path, compactJSON(dl.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", path, compactJSON(dl.Old), compactJSON(dl.New))
+}
+
+// Diff returns the differences between the values a and b in path order,
+// descending into slices, maps and structs to report what changed within them.
+func Diff(a, b Value) []Delta {
+	df := differ{
+		o: a.opts(),
+	}
+	df.diff("", a.v, b.v)
+	return df.deltas
+}
+
+// differ walks two vals in step collecting the differences
+type differ struct {
+	o      objOpts
+	deltas []Delta
+}
+
+func (df *differ) add(path string, op DeltaOp, a, b *val) {
+	dl := Delta{
+		Path: path,
+		Op:   op,
+	}
+	if a != nil {
+		dl.Old = a.obj(df.o)
+	}
+	if b != nil {
+		dl.New = b.obj(df.o)
+	}
+	df.deltas = append(df.deltas, dl)
+}
+
+func (df *differ) diff(path string, a, b *val) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		df.add(path, Added, nil, b)
+		return
+	case b == nil:
+		df.add(path, Removed, a, nil)
+		return
+	case kindOf(a.t, a.sl.array) != kindOf(b.t, b.sl.array):
+		df.add(path, Changed, a, b)
+		return
+	}
+
+	switch a.t {
+	case tSlice:
+		df.diffSlice(path, a, b)
+	case tMap:
+		df.diffMap(path, a, b)
+	case tStruct:
+		df.diffStruct(path, a, b)
+	case tBool:
+		if a.ToBool() != b.ToBool() {
+			df.add(path, Changed, a, b)
+		}
+	case tBytes, tString:
+		if !bytes.Equal(a.da, b.da) {
+			df.add(path, Changed, a, b)
+		}
+	default:
+		if a.nu != b.nu {
+			df.add(path, Changed, a, b)
+		}
+	}
+}
+
+func (df *differ) diffSlice(path string, a, b *val) {
+	ae, be := a.sl.els, b.sl.els
+	for i := 0; i < len(ae) || i < len(be); i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(ae):
+			df.add(p, Added, nil, &be[i])
+		case i >= len(be):
+			df.add(p, Removed, &ae[i], nil)
+		default:
+			df.diff(p, &ae[i], &be[i])
+		}
+	}
+}
+
+func (df *differ) diffMap(path string, a, b *val) {
+	var keys []string
+	for k := range a.ma.els {
+		keys = append(keys, k)
+	}
+	for k := range b.ma.els {
+		if _, ok := a.ma.els[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := fmt.Sprintf("%s[%s]", path, k)
+		av, aok := a.ma.els[k]
+		bv, bok := b.ma.els[k]
+		switch {
+		case !aok:
+			df.add(p, Added, nil, &bv)
+		case !bok:
+			df.add(p, Removed, &av, nil)
+		default:
+			df.diff(p, &av, &bv)
+		}
+	}
+}
+
+func (df *differ) diffStruct(path string, a, b *val) {
+	bf := map[string]*val{}
+	for i := range b.st {
+		bf[b.st[i].name] = &b.st[i].v
+	}
+	af := map[string]bool{}
+	for i := range a.st {
+		f := &a.st[i]
+		af[f.name] = true
+		p := joinPath(path, f.name)
+		bv, ok := bf[f.name]
+		if !ok {
+			df.add(p, Removed, &f.v, nil)
+			continue
+		}
+		df.diff(p, &f.v, bv)
+	}
+	// fields only in b, in their declared order
+	for i := range b.st {
+		f := &b.st[i]
+		if !af[f.name] {
+			df.add(joinPath(path, f.name), Added, nil, &f.v)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package goblin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	type inner struct {
+		Colour uint
+	}
+	type record struct {
+		Name    string
+		Age     int
+		Lengths []int
+		Tags    map[string]bool
+		Inner   inner
+	}
+	type record2 struct {
+		Name    string
+		Lengths []int
+		Tags    map[string]bool
+		Inner   inner
+		Height  float64
+	}
+
+	a := scanOne(t, record{
+		Name:    "goober",
+		Age:     19,
+		Lengths: []int{8, 1001, 3},
+		Tags:    map[string]bool{"a": true, "b": true},
+		Inner:   inner{Colour: 1},
+	}).Value()
+	b := scanOne(t, record2{
+		Name:    "gopher",
+		Lengths: []int{8, 1002},
+		Tags:    map[string]bool{"b": true, "c": true},
+		Inner:   inner{Colour: 2},
+		Height:  1.5,
+	}).Value()
+
+	deltas := Diff(a, b)
+	exp := []Delta{
+		{Path: "Name", Op: Changed, Old: "goober", New: "gopher"},
+		{Path: "Age", Op: Removed, Old: int64(19)},
+		{Path: "Lengths[1]", Op: Changed, Old: int64(1001), New: int64(1002)},
+		{Path: "Lengths[2]", Op: Removed, Old: int64(3)},
+		{Path: "Tags[a]", Op: Removed, Old: true},
+		{Path: "Tags[c]", Op: Added, New: true},
+		{Path: "Inner.Colour", Op: Changed, Old: uint64(1), New: uint64(2)},
+		{Path: "Height", Op: Added, New: 1.5},
+	}
+	if !reflect.DeepEqual(deltas, exp) {
+		for _, dl := range deltas {
+			t.Log(dl)
+		}
+		t.Fatal("unexpected deltas")
+	}
+	if deltas[2].String() != "~ Lengths[1]: 1001 -> 1002" {
+		t.Error("bad delta string", deltas[2])
+	}
+
+	if len(Diff(a, a)) != 0 {
+		t.Error("a value should not differ from itself")
+	}
+	deltas = Diff(scanOne(t, 1).Value(), scanOne(t, "1").Value())
+	if len(deltas) != 1 || deltas[0].Op != Changed {
+		t.Error("different kinds should change", deltas)
+	}
+}
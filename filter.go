@@ -0,0 +1,510 @@
+package goblin
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter is a test of a value, see SetFilter
+type Filter func(v Value) bool
+
+// SetFilter sets the filter that values must pass to be returned by Scan,
+// the rest are skipped. Nil returns all the values.
+func (d *decoder) SetFilter(f Filter) {
+	d.filter = f
+}
+
+// ParseFilter compiles a filter expression such as
+//
+//	Age > 30 && Name =~ "^go"
+//
+// Operands are paths into the value, as Lookup takes, len(path) for the
+// length of a string, slice, map or struct, numbers, Go quoted strings and
+// true and false. They are compared with == != < <= > >= and regular
+// expressions matched with =~ and !~. A comparison is false if a path does
+// not exist, and values of kinds that can not be compared are only unequal.
+// Ints, uints and floats compare by value. A bool path can be tested alone.
+// Tests are combined with && || ! and parentheses. The names true and false,
+// and len before a parenthesis, are always taken as above, so a path can
+// only start with a map key named true or false in brackets, as in ["true"].
+func ParseFilter(expr string) (Filter, error) {
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return f, nil
+}
+
+// Lookup returns the value at the path in v, struct field and map key names
+// separated by dots, with slice and array indexes, and map keys that are not
+// names, in brackets, such as Inner.Sizes[2] or Tags["a b"].
+func (v Value) Lookup(path string) (Value, bool) {
+	toks, err := lex(path)
+	if err != nil {
+		return Value{}, false
+	}
+	p := &filterParser{toks: toks}
+	steps, err := p.path()
+	if err != nil || p.peek().kind != tokEOF {
+		return Value{}, false
+	}
+	return v.walk(steps)
+}
+
+// step is a struct field, map key or index in a path
+type step string
+
+func (v Value) walk(steps []step) (Value, bool) {
+	for _, s := range steps {
+		ok := true
+		switch v.Kind() {
+		case Struct:
+			v, ok = v.Field(string(s))
+		case Map:
+			v, ok = v.MapIndex(string(s))
+		case Slice, Array:
+			i, err := strconv.Atoi(string(s))
+			v = v.Index(i)
+			ok = err == nil && v.IsValid()
+		default:
+			ok = false
+		}
+		if !ok {
+			return Value{}, false
+		}
+	}
+	return v, true
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type filterToken struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+// ops are the operators and punctuation, the two character ones first
+var ops = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "!", "<", ">", "(", ")", "[", "]", ".", "-"}
+
+func lex(s string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+			for i < len(s) && (s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] >= '0' && s[i] <= '9' || s[i] >= 0x80) {
+				i++
+			}
+			toks = append(toks, filterToken{tokIdent, s[start:i], start})
+			continue
+		case c >= '0' && c <= '9':
+			for i < len(s) && (s[i] == '.' || s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] >= '0' && s[i] <= '9' ||
+				(s[i] == '-' || s[i] == '+') && (s[i-1] == 'e' || s[i-1] == 'E')) {
+				i++
+			}
+			toks = append(toks, filterToken{tokNumber, s[start:i], start})
+			continue
+		case c == '"' || c == '`':
+			i++
+			for i < len(s) && s[i] != c {
+				if s[i] == '\\' && c == '"' {
+					i++
+				}
+				i++
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("at %d: unterminated string", start)
+			}
+			i++
+			str, err := strconv.Unquote(s[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("at %d: %v", start, err)
+			}
+			toks = append(toks, filterToken{tokString, str, start})
+			continue
+		}
+		op := ""
+		for _, o := range ops {
+			if strings.HasPrefix(s[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("at %d: unexpected %q", i, c)
+		}
+		toks = append(toks, filterToken{tokOp, op, i})
+		i += len(op)
+	}
+	return append(toks, filterToken{tokEOF, "", len(s)}), nil
+}
+
+type filterParser struct {
+	toks []filterToken
+	i    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.toks[p.i]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept moves past the operator op if it is next
+func (p *filterParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(op string) error {
+	if !p.accept(op) {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *filterParser) unexpected(t filterToken) error {
+	if t.kind == tokEOF {
+		return fmt.Errorf("at %d: unexpected end", t.pos)
+	}
+	return fmt.Errorf("at %d: unexpected %q", t.pos, t.text)
+}
+
+func (p *filterParser) or() (Filter, error) {
+	f, err := p.and()
+	for err == nil && p.accept("||") {
+		var g Filter
+		if g, err = p.and(); err == nil {
+			a, b := f, g
+			f = func(v Value) bool { return a(v) || b(v) }
+		}
+	}
+	return f, err
+}
+
+func (p *filterParser) and() (Filter, error) {
+	f, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var g Filter
+		if g, err = p.unary(); err == nil {
+			a, b := f, g
+			f = func(v Value) bool { return a(v) && b(v) }
+		}
+	}
+	return f, err
+}
+
+func (p *filterParser) unary() (Filter, error) {
+	if p.accept("!") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v Value) bool { return !f(v) }, nil
+	}
+	if p.accept("(") {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	}
+	return p.comparison()
+}
+
+// comparisons maps the comparison operators to the results of compare they are true for
+var comparisons = map[string][]int{
+	"==": {0},
+	"!=": {-1, 1, unordered},
+	"<":  {-1},
+	"<=": {-1, 0},
+	">":  {1},
+	">=": {1, 0},
+}
+
+func (p *filterParser) comparison() (Filter, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokOp && (t.text == "=~" || t.text == "!~") {
+		p.next()
+		r := p.next()
+		if r.kind != tokString {
+			return nil, fmt.Errorf("at %d: %s needs a regular expression string", r.pos, t.text)
+		}
+		re, err := regexp.Compile(r.text)
+		if err != nil {
+			return nil, fmt.Errorf("at %d: %v", r.pos, err)
+		}
+		want := t.text == "=~"
+		return func(v Value) bool {
+			a, ok := left(v)
+			return ok && a.k == String && re.MatchString(a.s) == want
+		}, nil
+	}
+	results, ok := comparisons[t.text]
+	if t.kind != tokOp || !ok {
+		// a bool on its own
+		return func(v Value) bool {
+			a, ok := left(v)
+			return ok && a.k == Bool && a.b
+		}, nil
+	}
+	p.next()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return func(v Value) bool {
+		a, ok := left(v)
+		if !ok {
+			return false
+		}
+		b, ok := right(v)
+		if !ok {
+			return false
+		}
+		c := compare(a, b)
+		for _, r := range results {
+			if c == r {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// operand returns the scalar of a constant or path, false if there is none
+type operand func(v Value) (scalar, bool)
+
+func (p *filterParser) operand() (operand, error) {
+	t := p.peek()
+	neg := t.kind == tokOp && t.text == "-"
+	if neg {
+		p.next()
+		t = p.peek()
+	}
+	switch {
+	case t.kind == tokNumber:
+		p.next()
+		s, err := parseNumber(t, neg)
+		if err != nil {
+			return nil, err
+		}
+		return func(Value) (scalar, bool) { return s, true }, nil
+	case neg:
+		return nil, p.unexpected(t)
+	case t.kind == tokString:
+		p.next()
+		s := scalar{k: String, s: t.text}
+		return func(Value) (scalar, bool) { return s, true }, nil
+	case t.kind == tokIdent && (t.text == "true" || t.text == "false"):
+		p.next()
+		s := scalar{k: Bool, b: t.text == "true"}
+		return func(Value) (scalar, bool) { return s, true }, nil
+	case t.kind == tokIdent && t.text == "len" && p.toks[p.i+1].text == "(":
+		p.i += 2
+		steps, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return func(v Value) (scalar, bool) {
+			v, ok := v.walk(steps)
+			if !ok {
+				return scalar{}, false
+			}
+			n := v.Len()
+			if k := v.Kind(); k == String || k == Bytes {
+				n = len(v.v.da)
+			}
+			return scalar{k: Int, i: int64(n)}, true
+		}, p.expect(")")
+	}
+	steps, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	return func(v Value) (scalar, bool) {
+		v, ok := v.walk(steps)
+		if !ok {
+			return scalar{}, false
+		}
+		return scalarOf(v)
+	}, nil
+}
+
+// path parses names separated by dots and bracketed indexes and keys, a
+// path can start with a bracket to index the value itself
+func (p *filterParser) path() ([]step, error) {
+	var steps []step
+	for {
+		t := p.peek()
+		switch {
+		case len(steps) == 0 && t.kind == tokIdent:
+			p.next()
+			steps = append(steps, step(t.text))
+		case len(steps) > 0 && p.accept("."):
+			if t = p.next(); t.kind != tokIdent {
+				return nil, p.unexpected(t)
+			}
+			steps = append(steps, step(t.text))
+		case p.accept("["):
+			t = p.next()
+			if t.kind != tokNumber && t.kind != tokString && t.kind != tokIdent {
+				return nil, p.unexpected(t)
+			}
+			steps = append(steps, step(t.text))
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		case len(steps) == 0:
+			return nil, p.unexpected(t)
+		default:
+			return steps, nil
+		}
+	}
+}
+
+// scalar is a bool, number or string to compare
+type scalar struct {
+	k Kind
+	b bool
+	i int64
+	u uint64
+	f float64
+	s string
+}
+
+// scalarOf returns the scalar of a bool, number, string or []byte value
+func scalarOf(v Value) (scalar, bool) {
+	switch k := v.Kind(); k {
+	case Bool:
+		return scalar{k: k, b: v.ToBool()}, true
+	case Int:
+		return scalar{k: k, i: v.ToInt()}, true
+	case Uint:
+		return scalar{k: k, u: v.ToUint()}, true
+	case Float:
+		return scalar{k: k, f: v.ToFloat()}, true
+	case String, Bytes:
+		return scalar{k: String, s: string(v.v.da)}, true
+	}
+	return scalar{}, false
+}
+
+func parseNumber(t filterToken, neg bool) (scalar, error) {
+	text := t.text
+	if neg {
+		text = "-" + text
+	}
+	if i, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return scalar{k: Int, i: i}, nil
+	}
+	if u, err := strconv.ParseUint(text, 0, 64); err == nil {
+		return scalar{k: Uint, u: u}, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return scalar{}, fmt.Errorf("at %d: bad number %q", t.pos, t.text)
+	}
+	return scalar{k: Float, f: f}, nil
+}
+
+func (s scalar) number() bool {
+	return s.k == Int || s.k == Uint || s.k == Float
+}
+
+func (s scalar) float() float64 {
+	switch s.k {
+	case Int:
+		return float64(s.i)
+	case Uint:
+		return float64(s.u)
+	}
+	return s.f
+}
+
+// unordered is the result of compare for values that have no order, which
+// are only ever unequal
+const unordered = 2
+
+// compare returns -1, 0 or 1 as a is less than, equal to or greater than b,
+// or unordered for mismatched kinds, bools that are not equal and NaN
+func compare(a, b scalar) int {
+	switch {
+	case a.number() && b.number():
+		if a.k == Float || b.k == Float {
+			fa, fb := a.float(), b.float()
+			if math.IsNaN(fa) || math.IsNaN(fb) {
+				return unordered
+			}
+			return order(fa < fb, fa > fb)
+		}
+		// ints and uints compare exactly
+		an, bn := a.k == Int && a.i < 0, b.k == Int && b.i < 0
+		if an || bn {
+			if !an || !bn {
+				return order(an, bn)
+			}
+			return order(a.i < b.i, a.i > b.i)
+		}
+		au, bu := a.u, b.u
+		if a.k == Int {
+			au = uint64(a.i)
+		}
+		if b.k == Int {
+			bu = uint64(b.i)
+		}
+		return order(au < bu, au > bu)
+	case a.k == String && b.k == String:
+		return strings.Compare(a.s, b.s)
+	case a.k == Bool && b.k == Bool && a.b == b.b:
+		return 0
+	}
+	return unordered
+}
+
+func order(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
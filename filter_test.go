@@ -0,0 +1,109 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type filterRec struct {
+	Name  string
+	Age   int
+	Size  uint64
+	Score float64
+	Ok    bool
+	Tags  map[string]int
+	Nums  []int
+	Inner csvInner
+}
+
+func TestFilter(t *testing.T) {
+	recs := []filterRec{
+		{Name: "goober", Age: 40, Size: math.MaxUint64, Score: 1.5, Ok: true, Tags: map[string]int{"a b": 1}, Nums: []int{-3, 4}, Inner: csvInner{Label: "red"}},
+		{Name: "gopher", Age: 12, Score: math.NaN(), Nums: []int{7}},
+		{Name: "ty", Age: 31, Size: 3, Tags: map[string]int{"x": 2}},
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for _, r := range recs {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+	name := func(d *decoder) string {
+		v, _ := d.Value().Lookup("Name")
+		return v.ToString()
+	}
+
+	cases := []struct {
+		expr string
+		exp  []string
+	}{
+		{`Age > 30 && Name =~ "^go"`, []string{"goober"}},
+		{`Age > 30 || Name == "gopher"`, []string{"goober", "gopher", "ty"}},
+		{`!(Age >= 31)`, []string{"gopher"}},
+		{`Ok`, []string{"goober"}},
+		{`!Ok && Name !~ "^t"`, []string{"gopher"}},
+		{`Size > 18446744073709551614`, []string{"goober"}},
+		{`Size > -1 && Size < 4.5`, []string{"gopher", "ty"}},
+		{`Score == 1.5`, []string{"goober"}},
+		{`Score != Score`, []string{"gopher"}}, // NaN
+		{`Tags["a b"] == 1 || Tags.x == 2`, []string{"goober", "ty"}},
+		{`Nums[0] < 0 || Nums[1] == 4`, []string{"goober"}},
+		{`len(Nums) == 1 || len(Name) == 2`, []string{"gopher", "ty"}},
+		{`Inner.Label == "red"`, []string{"goober"}},
+		{`Missing == 1 || Name == 1`, nil},
+		{`Name != 1`, []string{"goober", "gopher", "ty"}},
+	}
+	for _, c := range cases {
+		f, err := ParseFilter(c.expr)
+		if err != nil {
+			t.Fatal(c.expr, err)
+		}
+		d := New(bytes.NewReader(stream))
+		d.SetFilter(f)
+		var got []string
+		for d.Scan() {
+			got = append(got, name(d))
+		}
+		if d.Err() != nil {
+			t.Fatal(d.Err())
+		}
+		if !reflect.DeepEqual(got, c.exp) {
+			t.Errorf("%s: got %q", c.expr, got)
+		}
+	}
+
+	for _, expr := range []string{``, `Age >`, `(Ok`, `Name =~ 3`, `Name =~ "("`, `Age == 1 1`, `"unterminated`, `Age # 3`, `Nums[`} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+
+	// a go func filter
+	d := New(bytes.NewReader(stream))
+	d.SetFilter(func(v Value) bool {
+		n, _ := v.Lookup("Nums[0]")
+		return n.ToInt() > 0
+	})
+	if !d.Scan() || name(d) != "gopher" || d.Scan() {
+		t.Error("expected only gopher")
+	}
+	// keys that are also the names of the expression
+	buf.Reset()
+	if err := gob.NewEncoder(buf).Encode(map[string]int{"true": 1, "len": 2}); err != nil {
+		t.Fatal("shame", err)
+	}
+	f, err := ParseFilter(`["true"] == 1 && ["len"] == 2 && len == 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = New(bytes.NewReader(buf.Bytes()))
+	d.SetFilter(f)
+	if !d.Scan() {
+		t.Error("expected the map", d.Err())
+	}
+}
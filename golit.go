@@ -0,0 +1,121 @@
+package goblin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GoLiteral returns the result of the last Scan as a Go composite literal,
+// see Value.GoString
+func (d *decoder) GoLiteral() ([]byte, error) {
+	if d.lastVal == nil {
+		return nil, errors.New("can not return go representation of none existant object")
+	}
+	return []byte(d.Value().GoString()), nil
+}
+
+// GoString returns the value as Go source, a composite literal for slices,
+// maps and structs, which makes Value a fmt.GoStringer for %#v. The types are
+// named by TypeName, so the literal uses the types that WriteTypes declares,
+// or those declared in the source given to TypeHints. Absent fields are left
+// out as they are zero.
+func (v Value) GoString() string {
+	if v.v == nil {
+		return "nil"
+	}
+	var b strings.Builder
+	v.goLit(&b, false)
+	return b.String()
+}
+
+// goLit writes the literal of v, without its type if elided, as it is in the
+// elements of slices and maps
+func (v Value) goLit(b *strings.Builder, elided bool) {
+	k := v.Kind()
+	if k < Bytes {
+		b.WriteString(v.goConst())
+		return
+	}
+	if k == String {
+		b.WriteString(strconv.Quote(v.ToString()))
+		return
+	}
+	if !elided {
+		ty := v.TypeName()
+		if strings.HasPrefix(ty, "*") {
+			// gob flattens pointers, only hints know them
+			b.WriteByte('&')
+			ty = ty[1:]
+		}
+		b.WriteString(ty)
+	}
+
+	b.WriteByte('{')
+	switch k {
+	case Bytes:
+		for i, c := range v.ToBytes() {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "%#x", c)
+		}
+	case Slice, Array:
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			v.Index(i).goLit(b, true)
+		}
+	case Map:
+		for i, key := range v.MapKeys() {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			key.goLit(b, true)
+			b.WriteString(": ")
+			e, _ := v.MapIndex(key.v.key())
+			e.goLit(b, true)
+		}
+	case Struct:
+		n := 0
+		for _, name := range v.Fields() {
+			if !v.Present(name) {
+				continue
+			}
+			if n > 0 {
+				b.WriteString(", ")
+			}
+			n++
+			f, _ := v.Field(name)
+			b.WriteString(name)
+			b.WriteString(": ")
+			f.goLit(b, false)
+		}
+	}
+	b.WriteByte('}')
+}
+
+// goConst returns the constant of a bool, int, uint or float
+func (v Value) goConst() string {
+	switch v.Kind() {
+	case Bool:
+		return strconv.FormatBool(v.ToBool())
+	case Int:
+		return strconv.FormatInt(v.ToInt(), 10)
+	case Uint:
+		return strconv.FormatUint(v.ToUint(), 10)
+	}
+	f := v.ToFloat()
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,86 @@
+package goblin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGoLiteral(t *testing.T) {
+	type other struct {
+		Colour uint
+	}
+	type bart struct {
+		Name    string
+		Age     int
+		Sane    bool
+		Lengths []int
+		Other   *other
+		Blob    []byte
+		Sizes   [2]int
+		Tags    map[string]float64
+		Gone    string
+	}
+
+	d := scanOne(t, bart{
+		Name:    "goober",
+		Age:     -19,
+		Sane:    true,
+		Lengths: []int{8, 1001},
+		Other:   &other{Colour: 7},
+		Blob:    []byte{9, 0x55},
+		Sizes:   [2]int{0, 25},
+		Tags:    map[string]float64{"b": 1.5, "a": 2},
+	})
+	b, err := d.GoLiteral()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `bart{Name: "goober", Age: -19, Sane: true, Lengths: []int64{8, 1001}, Other: other{Colour: 7}, ` +
+		`Blob: []byte{0x9, 0x55}, Sizes: [2]int64{0, 25}, Tags: map[string]float64{"a": 2, "b": 1.5}}`
+	if string(b) != exp {
+		t.Errorf("got %s", b)
+	}
+	if got := fmt.Sprintf("%#v", d.Value()); got != exp {
+		t.Errorf("bad GoString %s", got)
+	}
+
+	// hints give the declared types, and pointers
+	d = scanOne(t, bart{Lengths: []int{1}, Other: &other{Colour: 7}})
+	err = d.TypeHints(strings.NewReader(`package x
+		type other struct {
+			Colour uint
+		}
+		type bart struct {
+			Lengths []int
+			Other   *other
+			Sizes   [2]int
+		}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = `bart{Lengths: []int{1}, Other: &other{Colour: 7}, Sizes: [2]int{0, 0}}`
+	if got := d.Value().GoString(); got != exp {
+		t.Errorf("got hinted %s", got)
+	}
+
+	// named slices use the type WriteTypes declares
+	type levels []int
+	type graph struct {
+		Levels levels
+	}
+	d = scanOne(t, graph{Levels: levels{1, 2}})
+	if got := d.Value().GoString(); got != `graph{Levels: levels{1, 2}}` {
+		t.Errorf("got named %s", got)
+	}
+	buf := &strings.Builder{}
+	d.WriteTypes(buf)
+	if !strings.Contains(buf.String(), "type levels []int64\n") || !strings.Contains(buf.String(), "  Levels levels\n") {
+		t.Errorf("got types %s", buf)
+	}
+
+	d = scanOne(t, 12.5)
+	if got := d.Value().GoString(); got != "12.5" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,183 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+)
+
+// basicKinds are the wire types of the predeclared Go types
+var basicKinds = map[string]typeID{
+	"bool":    tBool,
+	"int":     tInt,
+	"int8":    tInt,
+	"int16":   tInt,
+	"int32":   tInt,
+	"int64":   tInt,
+	"rune":    tInt,
+	"uint":    tUint,
+	"uint8":   tUint,
+	"uint16":  tUint,
+	"uint32":  tUint,
+	"uint64":  tUint,
+	"uintptr": tUint,
+	"byte":    tUint,
+	"float32": tFloat,
+	"float64": tFloat,
+	"string":  tString,
+}
+
+// hints are the original Go types declared in source. Gob only sends
+// int64, uint64 and float64, and drops pointers, so these restore them.
+type hints struct {
+	fields map[string]map[string]ast.Expr // struct name to field name to its declared type
+	named  map[string]ast.Expr            // type name to its declared type
+}
+
+// TypeHints parses the Go source in src for type declarations. Where a struct
+// of the same name has a field whose declared type is compatible with the
+// type sent on the wire, the declared type is reported instead, by WriteTypes
+// and Value.TypeName, restoring the original widths, pointers and type names.
+// Absent pointer fields are rendered as nil by AbsentZero, as Go has them.
+// It can be called again to add the declarations from more files.
+func (d *decoder) TypeHints(src io.Reader) error {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return err
+	}
+	if d.hints.fields == nil {
+		d.hints.fields = map[string]map[string]ast.Expr{}
+		d.hints.named = map[string]ast.Expr{}
+	}
+	d.plans = nil // the zero values of pointer fields change
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			d.hints.named[ts.Name.Name] = ts.Type
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			fs := map[string]ast.Expr{}
+			for _, fld := range st.Fields.List {
+				// gob names embedded fields by their type
+				if len(fld.Names) == 0 {
+					if n := embeddedName(fld.Type); n != "" {
+						fs[n] = fld.Type
+					}
+				}
+				for _, n := range fld.Names {
+					fs[n.Name] = fld.Type
+				}
+			}
+			d.hints.fields[ts.Name.Name] = fs
+		}
+	}
+	return nil
+}
+
+func embeddedName(e ast.Expr) string {
+	switch x := e.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.StarExpr:
+		return embeddedName(x.X)
+	case *ast.SelectorExpr:
+		return x.Sel.Name
+	}
+	return ""
+}
+
+// field returns the declared type of the named field of the named struct,
+// or nil if there is none or it could not have been sent as wire kind t
+func (h hints) field(structName, name string, t typeID) ast.Expr {
+	fs, ok := h.fields[structName]
+	if !ok {
+		return nil
+	}
+	return h.check(fs[name], t)
+}
+
+// ptr reports whether the named field of the named struct is declared as a
+// pointer, and could have been sent as wire kind t
+func (h hints) ptr(structName, name string, t typeID) bool {
+	e := h.field(structName, name, t)
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.StarExpr:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// elem returns the declared element type of a slice, array or map
+// type e, or nil if it is not one, or could not have been sent as t
+func (h hints) elem(e ast.Expr, t typeID) ast.Expr {
+	switch x := h.deref(e).(type) {
+	case *ast.ArrayType:
+		return h.check(x.Elt, t)
+	case *ast.MapType:
+		return h.check(x.Value, t)
+	}
+	return nil
+}
+
+// check returns e if a value of the declared type e would be sent as wire kind t
+func (h hints) check(e ast.Expr, t typeID) ast.Expr {
+	if e == nil || h.kind(e) != t {
+		return nil
+	}
+	return e
+}
+
+// deref strips pointers and parentheses from e, and resolves named types
+// to their declarations
+func (h hints) deref(e ast.Expr) ast.Expr {
+	for i := 0; i < 10; i++ { // guard against recursive declarations
+		switch x := e.(type) {
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.Ident:
+			u, ok := h.named[x.Name]
+			if !ok {
+				return e
+			}
+			e = u
+		default:
+			return e
+		}
+	}
+	return e
+}
+
+// kind returns the wire kind that gob sends a value of type e as, or zero if not known
+func (h hints) kind(e ast.Expr) typeID {
+	switch x := h.deref(e).(type) {
+	case *ast.Ident:
+		return basicKinds[x.Name]
+	case *ast.ArrayType:
+		// only slices of bytes are sent as bytes, arrays are sent element by element
+		if x.Len == nil {
+			if id, ok := h.deref(x.Elt).(*ast.Ident); ok && (id.Name == "byte" || id.Name == "uint8") {
+				return tBytes
+			}
+		}
+		return tSlice
+	case *ast.MapType:
+		return tMap
+	case *ast.StructType:
+		return tStruct
+	}
+	return 0
+}
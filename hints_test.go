@@ -0,0 +1,139 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+type Celsius float32
+
+type hintInner struct {
+	Small uint16
+}
+
+type hinted struct {
+	Tiny   int8
+	Mid    int32
+	Wide   int
+	Temp   Celsius
+	Ratio  float32
+	Inner  *hintInner
+	Counts []int32
+	Named  map[string]uint8
+	Blob   []byte
+	Wrong  string
+}
+
+var hintSrc = `package hinted
+
+type Celsius float32
+
+type hintInner struct {
+	Small uint16
+}
+
+type hinted struct {
+	Tiny   int8
+	Mid    int32
+	Wide   int
+	Temp   Celsius
+	Ratio  float32
+	Inner  *hintInner
+	Counts []int32
+	Named  map[string]uint8
+	Blob   []byte
+	Wrong  int // not what was sent so ignored
+}
+`
+
+func TestTypeHints(t *testing.T) {
+	d := scanOne(t, hinted{
+		Tiny:   -3,
+		Inner:  &hintInner{Small: 4},
+		Counts: []int32{1, 2},
+		Named:  map[string]uint8{"a": 1},
+	})
+	if err := d.TypeHints(strings.NewReader(hintSrc)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	d.WriteTypes(buf)
+	for _, exp := range []string{
+		"  Tiny int8\n",
+		"  Mid int32\n",
+		"  Wide int\n",
+		"  Temp Celsius\n",
+		"  Ratio float32\n",
+		"  Inner *hintInner\n",
+		"  Counts []int32\n",
+		"  Named map[string]uint8\n",
+		"  Blob []byte\n",
+		"  Wrong string\n",
+		"  Small uint16\n",
+	} {
+		if !strings.Contains(buf.String(), exp) {
+			t.Errorf("types missing %q", exp)
+		}
+	}
+
+	v := d.Value()
+	if v.TypeName() != "hinted" {
+		t.Error("bad struct type name", v.TypeName())
+	}
+	for name, exp := range map[string]string{
+		"Tiny":   "int8",
+		"Temp":   "Celsius",
+		"Inner":  "*hintInner",
+		"Counts": "[]int32",
+		"Wrong":  "string",
+	} {
+		f, _ := v.Field(name)
+		if f.TypeName() != exp {
+			t.Errorf("%s got type %s expected %s", name, f.TypeName(), exp)
+		}
+	}
+	counts, _ := v.Field("Counts")
+	if counts.Index(0).TypeName() != "int32" {
+		t.Error("bad slice element type", counts.Index(0).TypeName())
+	}
+	named, _ := v.Field("Named")
+	a, _ := named.MapIndex("a")
+	if a.TypeName() != "uint8" {
+		t.Error("bad map element type", a.TypeName())
+	}
+	inner, _ := v.Field("Inner")
+	small, _ := inner.Field("Small")
+	if small.TypeName() != "uint16" {
+		t.Error("bad nested field type", small.TypeName())
+	}
+
+	// without hints the wire types are reported
+	d = scanOne(t, hinted{Tiny: 1})
+	tiny, _ := d.Value().Field("Tiny")
+	if tiny.TypeName() != "int64" {
+		t.Error("bad unhinted type", tiny.TypeName())
+	}
+	// absent pointer fields are nil, once the hints say they are pointers
+	buf.Reset()
+	if err := gob.NewEncoder(buf).Encode(hinted{Tiny: 1}); err != nil {
+		t.Fatal("shame", err)
+	}
+	for _, hint := range []bool{false, true} {
+		d = New(bytes.NewReader(buf.Bytes()))
+		if hint {
+			if err := d.TypeHints(strings.NewReader(hintSrc)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !d.Scan() {
+			t.Fatal(d.Err())
+		}
+		inner := d.Obj().(map[string]interface{})["Inner"]
+		if (inner == nil) != hint {
+			t.Errorf("hinted %t got inner %v", hint, inner)
+		}
+	}
+}
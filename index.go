@@ -0,0 +1,140 @@
+package goblin
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Index holds the byte offsets of the messages in a gob stream so that
+// values can be decoded in any order, see NewAt
+type Index struct {
+	Types  []int64 // the offsets of the type definition messages
+	Values []int64 // the offsets of the value messages
+}
+
+// BuildIndex reads the whole gob stream in r recording the offset of each
+// message, without decoding them
+func BuildIndex(r io.Reader) (*Index, error) {
+	d := New(r)
+	ix := &Index{}
+	for {
+		if err := d.getBuf(); err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", d.off, err)
+		}
+		if len(d.b) == 0 {
+			return ix, nil
+		}
+		typ, err := msgType(d.b)
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", d.msgOff, err)
+		}
+		if typ < 0 {
+			ix.Types = append(ix.Types, d.msgOff)
+		} else {
+			ix.Values = append(ix.Values, d.msgOff)
+		}
+	}
+}
+
+// ReadIndex reads an index written by WriteTo
+func ReadIndex(r io.Reader) (*Index, error) {
+	ix := &Index{}
+	if err := gob.NewDecoder(r).Decode(ix); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// WriteTo writes the index to w, as gob of course, to be stored alongside the stream
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	err := gob.NewEncoder(cw).Encode(ix)
+	return cw.n, err
+}
+
+// Len returns the number of values indexed
+func (ix *Index) Len() int {
+	return len(ix.Values)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// msgType returns the type id of the whole message b, negative for a type definition
+func msgType(b []byte) (int64, error) {
+	d := decoder{b: b}
+	// skip the length
+	if _, err := d.decodeUint(); err != nil {
+		return 0, err
+	}
+	return d.decodeInt()
+}
+
+// NewAt returns a decoder of the gob stream in ra with the messages in ix.
+// All the types are loaded up front so Seek can move to any value.
+func NewAt(ra io.ReaderAt, ix *Index) (*decoder, error) {
+	d := &decoder{
+		ra: ra,
+		ix: ix,
+	}
+	d.initTypes()
+	for _, off := range ix.Types {
+		d.seekTo(off)
+		if err := d.getBuf(); err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", off, err)
+		}
+		isType, err := d.decodeType()
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", off, err)
+		}
+		if !isType {
+			return nil, fmt.Errorf("at offset %d: the index does not match the stream", off)
+		}
+	}
+	if d.segment > 0 {
+		return nil, errors.New("can not seek in concatenated gob streams")
+	}
+	// so that Scan does not take them for the types of a new stream
+	d.loaded = map[int64]bool{}
+	for _, off := range ix.Types {
+		d.loaded[off] = true
+	}
+	if len(ix.Values) == 0 {
+		d.r = io.MultiReader() // nothing to scan
+		return d, nil
+	}
+	d.seekTo(ix.Values[0])
+	return d, nil
+}
+
+// Seek moves a decoder made by NewAt so that the next Scan decodes value n,
+// counting from 0, and Scans after that carry on from there.
+func (d *decoder) Seek(n int) error {
+	if d.ra == nil {
+		return errors.New("can only seek a decoder made by NewAt")
+	}
+	if n < 0 || n >= len(d.ix.Values) {
+		return fmt.Errorf("can not seek to value %d of %d", n, len(d.ix.Values))
+	}
+	d.seekTo(d.ix.Values[n])
+	d.lastVal = nil
+	d.lastErr = nil
+	return nil
+}
+
+func (d *decoder) seekTo(off int64) {
+	d.r = bufio.NewReader(io.NewSectionReader(d.ra, off, math.MaxInt64-off))
+	d.off = off
+}
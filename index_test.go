@@ -0,0 +1,130 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	type record struct {
+		N    int
+		Name string
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < 50; i++ {
+		r := record{N: i}
+		if i%2 == 0 {
+			r.Name = "even"
+		}
+		if err := enc.Encode(r); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+
+	ix, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 50 || len(ix.Types) != 1 {
+		t.Fatalf("bad index %d values %d types", ix.Len(), len(ix.Types))
+	}
+
+	// round trip the sidecar
+	side := &bytes.Buffer{}
+	n, err := ix.WriteTo(side)
+	if err != nil || n != int64(side.Len()) {
+		t.Fatal("bad index write", n, err)
+	}
+	ix, err = ReadIndex(side)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewAt(bytes.NewReader(stream), ix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{37, 3, 49, 0} {
+		if err := d.Seek(i); err != nil {
+			t.Fatal(err)
+		}
+		if !d.Scan() {
+			t.Fatal("could not scan after seek", d.Err())
+		}
+		nv, _ := d.Value().Field("N")
+		if nv.ToInt() != int64(i) {
+			t.Errorf("seek to %d got %d", i, nv.ToInt())
+		}
+	}
+	// scanning carries on from the seek
+	if !d.Scan() {
+		t.Fatal("could not scan on", d.Err())
+	}
+	if nv, _ := d.Value().Field("N"); nv.ToInt() != 1 {
+		t.Error("did not scan on to 1", nv.ToInt())
+	}
+	if err := d.Seek(50); err == nil {
+		t.Error("should not seek beyond the values")
+	}
+}
+
+func TestSmallMessages(t *testing.T) {
+	// each message is shorter than a uint64 so must not be read past
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < 5; i++ {
+		if err := enc.Encode(i); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	d := New(buf)
+	i := int64(0)
+	for d.Scan() {
+		if d.Obj().(int64) != i {
+			t.Errorf("got %v expected %d", d.Obj(), i)
+		}
+		i++
+	}
+	if d.Err() != nil || i != 5 {
+		t.Error("did not scan all the values", i, d.Err())
+	}
+}
+
+func TestNewAtSegments(t *testing.T) {
+	type a struct {
+		N int
+	}
+	type b struct {
+		S string
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for _, v := range []interface{}{a{1}, b{"b"}, a{2}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+	ix, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the type of b, defined mid stream, is loaded up front, but is not a new stream
+	d, err := NewAt(bytes.NewReader(stream), ix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for ; d.Scan(); i++ {
+		if d.Segment() != 0 {
+			t.Errorf("value %d in segment %d", i, d.Segment())
+		}
+	}
+	if d.Err() != nil || i != 3 {
+		t.Error("did not scan all the values", i, d.Err())
+	}
+}
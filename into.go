@@ -0,0 +1,328 @@
+package goblin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Report lists the paths of the data that Into could not faithfully decode
+type Report struct {
+	Unmatched []string // struct fields in the data with no matching Go field
+	Truncated []string // numbers changed to fit the Go type, or array elements that did not fit
+	Dropped   []string // values the Go type could not hold at all
+}
+
+// OK returns true if all the data was decoded
+func (r Report) OK() bool {
+	return len(r.Unmatched) == 0 && len(r.Truncated) == 0 && len(r.Dropped) == 0
+}
+
+// Into decodes the result of the last Scan into dst, see Value.Into
+func (d *decoder) Into(dst interface{}) (Report, error) {
+	if d.lastVal == nil {
+		return Report{}, errors.New("can not decode none existant object")
+	}
+	return d.Value().Into(dst)
+}
+
+// Into decodes the value into dst, which must be a non nil pointer. Unlike
+// encoding/gob it is lenient about the shape of dst, struct fields are matched
+// by name ignoring case and numbers are converted between any of the numeric
+// kinds. Anything that could not be decoded exactly is listed in the report.
+// Fields that were absent from the data are left untouched, as gob does.
+// Everything that could be decoded is set in dst even when the report is not
+// OK, so what was in dst is partly overwritten, and what was not decoded, such
+// as dropped values, keeps what dst held before.
+func (v Value) Into(dst interface{}) (Report, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return Report{}, errors.New("can only decode into a non nil pointer")
+	}
+	if v.v == nil {
+		return Report{}, errors.New("can not decode an invalid value")
+	}
+	in := intoer{}
+	in.set("", v.v, rv.Elem())
+	return in.r, nil
+}
+
+// intoer decodes vals into reflect values collecting a report
+type intoer struct {
+	r Report
+}
+
+func (in *intoer) set(path string, v *val, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		in.set(path, v, rv.Elem())
+		return
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			in.drop(path)
+			return
+		}
+		if o := v.obj(objOpts{}); o != nil {
+			rv.Set(reflect.ValueOf(o))
+		}
+		return
+	}
+
+	switch v.t {
+	case tBool:
+		if rv.Kind() != reflect.Bool {
+			in.drop(path)
+			return
+		}
+		rv.SetBool(v.ToBool())
+	case tInt:
+		in.setInt(path, v.ToInt(), rv)
+	case tUint:
+		in.setUint(path, v.ToUint(), rv)
+	case tFloat:
+		in.setFloat(path, v.ToFloat(), rv)
+	case tString, tBytes:
+		switch {
+		case rv.Kind() == reflect.String:
+			rv.SetString(string(v.da))
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			b := make([]byte, len(v.da))
+			copy(b, v.da)
+			rv.SetBytes(b)
+		default:
+			in.drop(path)
+		}
+	case tSlice:
+		in.setSlice(path, v, rv)
+	case tMap:
+		in.setMap(path, v, rv)
+	case tStruct:
+		in.setStruct(path, v, rv)
+	default:
+		in.drop(path)
+	}
+}
+
+func (in *intoer) setSlice(path string, v *val, rv reflect.Value) {
+	els := v.sl.els
+	switch rv.Kind() {
+	case reflect.Slice:
+		rv.Set(reflect.MakeSlice(rv.Type(), len(els), len(els)))
+	case reflect.Array:
+	default:
+		in.drop(path)
+		return
+	}
+	for i := range els {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if i >= rv.Len() {
+			in.r.Truncated = append(in.r.Truncated, p)
+			continue
+		}
+		in.set(p, &els[i], rv.Index(i))
+	}
+}
+
+func (in *intoer) setMap(path string, v *val, rv reflect.Value) {
+	if rv.Kind() != reflect.Map {
+		in.drop(path)
+		return
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+	kt := rv.Type().Key()
+	et := rv.Type().Elem()
+	// sorted for a stable report
+	for _, key := range v.ma.sortedKeys() {
+		k := key.key()
+		p := fmt.Sprintf("%s[%s]", path, k)
+		var kv reflect.Value
+		switch key.t {
+		case tStruct, tSlice:
+			// struct and array keys are set like any other value
+			if kt.Kind() != reflect.Struct && kt.Kind() != reflect.Array {
+				in.drop(p)
+				continue
+			}
+			kv = reflect.New(kt).Elem()
+			in.set(p, &key, kv)
+		default:
+			var ok bool
+			if kv, ok = parseKey(k, kt); !ok {
+				in.drop(p)
+				continue
+			}
+		}
+		ev := reflect.New(et).Elem()
+		e := v.ma.els[k]
+		in.set(p, &e, ev)
+		rv.SetMapIndex(kv, ev)
+	}
+}
+
+// parseKey converts the string representation of a map key into a value of type t
+func parseKey(k string, t reflect.Type) (reflect.Value, bool) {
+	kv := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		kv.SetString(k)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(k)
+		if err != nil {
+			return kv, false
+		}
+		kv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(k, 10, t.Bits())
+		if err != nil {
+			return kv, false
+		}
+		kv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(k, 10, t.Bits())
+		if err != nil {
+			return kv, false
+		}
+		kv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(k, t.Bits())
+		if err != nil {
+			return kv, false
+		}
+		kv.SetFloat(f)
+	default:
+		return kv, false
+	}
+	return kv, true
+}
+
+func (in *intoer) setStruct(path string, v *val, rv reflect.Value) {
+	if rv.Kind() != reflect.Struct {
+		in.drop(path)
+		return
+	}
+	for i := range v.st {
+		f := &v.st[i]
+		if !f.nonZero {
+			continue
+		}
+		p := f.name
+		if path != "" {
+			p = path + "." + f.name
+		}
+		fv, ok := fieldByName(rv, f.name)
+		if !ok {
+			in.r.Unmatched = append(in.r.Unmatched, p)
+			continue
+		}
+		in.set(p, &f.v, fv)
+	}
+}
+
+// fieldByName finds the exported field of rv with the name, preferring an
+// exact match over one that differs in case
+func fieldByName(rv reflect.Value, name string) (reflect.Value, bool) {
+	rt := rv.Type()
+	fold := -1
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		if sf.Name == name {
+			return rv.Field(i), true
+		}
+		if fold < 0 && strings.EqualFold(sf.Name, name) {
+			fold = i
+		}
+	}
+	if fold < 0 {
+		return reflect.Value{}, false
+	}
+	return rv.Field(fold), true
+}
+
+func (in *intoer) setInt(path string, i int64, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.OverflowInt(i) {
+			in.truncate(path)
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i < 0 || rv.OverflowUint(uint64(i)) {
+			in.truncate(path)
+		}
+		rv.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f := float64(i)
+		if int64(f) != i || rv.OverflowFloat(f) {
+			in.truncate(path)
+		}
+		rv.SetFloat(f)
+	default:
+		in.drop(path)
+	}
+}
+
+func (in *intoer) setUint(path string, u uint64, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if u > math.MaxInt64 || rv.OverflowInt(int64(u)) {
+			in.truncate(path)
+		}
+		rv.SetInt(int64(u))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.OverflowUint(u) {
+			in.truncate(path)
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f := float64(u)
+		if uint64(f) != u || rv.OverflowFloat(f) {
+			in.truncate(path)
+		}
+		rv.SetFloat(f)
+	default:
+		in.drop(path)
+	}
+}
+
+func (in *intoer) setFloat(path string, f float64, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if rv.OverflowFloat(f) {
+			in.truncate(path)
+		}
+		rv.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := int64(f)
+		if float64(i) != f || rv.OverflowInt(i) {
+			in.truncate(path)
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := uint64(f)
+		if f < 0 || float64(u) != f || rv.OverflowUint(u) {
+			in.truncate(path)
+		}
+		rv.SetUint(u)
+	default:
+		in.drop(path)
+	}
+}
+
+func (in *intoer) truncate(path string) {
+	in.r.Truncated = append(in.r.Truncated, path)
+}
+
+func (in *intoer) drop(path string) {
+	in.r.Dropped = append(in.r.Dropped, path)
+}
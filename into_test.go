@@ -0,0 +1,96 @@
+package goblin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInto(t *testing.T) {
+	type sub struct {
+		Colour uint
+	}
+	type written struct {
+		Name    string
+		Age     int
+		Big     int64
+		Height  float64
+		Lengths []int
+		Sizes   [3]int
+		Other   *sub
+		Scores  map[int]string
+		Gone    string
+		Kind    string
+	}
+	type drifted struct {
+		NAME    string // case differs
+		Age     int8
+		Big     int16 // too small
+		Height  int   // float with a fraction
+		Lengths []uint
+		Sizes   [2]int // too short
+		Other   struct{ Colour float32 }
+		Scores  map[uint8]string
+		Kind    int // can not hold a string
+		Extra   bool
+	}
+
+	d := scanOne(t, written{
+		Name:    "goober",
+		Age:     19,
+		Big:     100000,
+		Height:  1.5,
+		Lengths: []int{8, -1},
+		Sizes:   [3]int{1, 2, 3},
+		Other:   &sub{Colour: 7},
+		Scores:  map[int]string{1: "a", 300: "b"},
+		Gone:    "away",
+		Kind:    "blue",
+	})
+
+	var out drifted
+	out.Extra = true
+	r, err := d.Into(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := drifted{
+		NAME:    "goober",
+		Age:     19,
+		Big:     int16(-31072), // 100000 truncated
+		Height:  1,
+		Lengths: []uint{8, 18446744073709551615},
+		Sizes:   [2]int{1, 2},
+		Other:   struct{ Colour float32 }{Colour: 7},
+		Scores:  map[uint8]string{1: "a"},
+		Extra:   true,
+	}
+	if !reflect.DeepEqual(out, exp) {
+		t.Errorf("got %+v", out)
+	}
+
+	expR := Report{
+		Unmatched: []string{"Gone"},
+		Truncated: []string{"Big", "Height", "Lengths[1]", "Sizes[2]"},
+		Dropped:   []string{"Scores[300]", "Kind"},
+	}
+	if !reflect.DeepEqual(r, expR) {
+		t.Errorf("got report %+v", r)
+	}
+	if r.OK() {
+		t.Error("report should not be ok")
+	}
+
+	var any interface{}
+	r, err = d.Into(&any)
+	if err != nil || !r.OK() {
+		t.Error("should decode into an interface", err, r)
+	}
+	if any.(map[string]interface{})["Name"] != "goober" {
+		t.Error("bad interface value", any)
+	}
+
+	if _, err := d.Into(out); err == nil {
+		t.Error("should not decode into a non pointer")
+	}
+}
@@ -0,0 +1,54 @@
+//go:build go1.23
+// +build go1.23
+
+package goblin
+
+import "iter"
+
+// Values returns an iterator over the values remaining in the stream, for
+// use with range. Breaking out of the loop stops reading the stream, and any
+// error is yielded once as the last item with an invalid Value.
+func (d *decoder) Values() iter.Seq2[Value, error] {
+	return func(yield func(Value, error) bool) {
+		for d.Scan() {
+			if !yield(d.Value(), nil) {
+				return
+			}
+		}
+		if d.Err() != nil {
+			yield(Value{}, d.Err())
+		}
+	}
+}
+
+// Objects returns an iterator over the values remaining in the stream as
+// returned by Obj, otherwise it behaves as Values.
+func (d *decoder) Objects() iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		for v, err := range d.Values() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(v.Obj(), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect gathers the items from Values or Objects into a slice, stopping
+// after limit items if limit is greater than zero, or at the first error.
+func Collect[T any](seq iter.Seq2[T, error], limit int) ([]T, error) {
+	var all []T
+	for v, err := range seq {
+		if err != nil {
+			return all, err
+		}
+		all = append(all, v)
+		if limit > 0 && len(all) == limit {
+			break
+		}
+	}
+	return all, nil
+}
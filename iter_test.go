@@ -0,0 +1,62 @@
+//go:build go1.23
+// +build go1.23
+
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < 10; i++ {
+		if err := enc.Encode(i); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+
+	n := int64(0)
+	for v, err := range New(bytes.NewReader(stream)).Values() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.ToInt() != n {
+			t.Errorf("got %d expected %d", v.ToInt(), n)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Error("did not get all the values", n)
+	}
+
+	// breaking stops reading so scanning picks up where it left off
+	d := New(bytes.NewReader(stream))
+	for v := range d.Values() {
+		if v.ToInt() == 3 {
+			break
+		}
+	}
+	if !d.Scan() || d.Obj().(int64) != 4 {
+		t.Error("should carry on from after the break", d.Obj())
+	}
+
+	objs, err := Collect(New(bytes.NewReader(stream)).Objects(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 4 || objs[3].(int64) != 3 {
+		t.Error("bad limited objects", objs)
+	}
+
+	vals, err := Collect(New(bytes.NewReader(stream[:len(stream)-1])).Values(), 0)
+	if err == nil {
+		t.Error("expected an error from the truncated stream")
+	}
+	if len(vals) != 9 {
+		t.Error("should have collected the values before the error", len(vals))
+	}
+}
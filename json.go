@@ -0,0 +1,100 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// maxSafeInt is the largest integer that a float64, and so JavaScript, can
+// represent along with all the integers below it
+const maxSafeInt = 1 << 53
+
+// NonFiniteMode selects how NaN and infinite floats are rendered
+type NonFiniteMode int
+
+const (
+	// NonFiniteError leaves them as floats so marshalling to json fails
+	NonFiniteError NonFiniteMode = iota
+	// NonFiniteString renders them as the strings "NaN", "+Inf" and "-Inf"
+	NonFiniteString
+	// NonFiniteNull renders them as nil, which is null in json
+	NonFiniteNull
+)
+
+// float returns f or its replacement if it is not finite
+func (o objOpts) float(f float64) interface{} {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f
+	}
+	switch o.nonFinite {
+	case NonFiniteString:
+		switch {
+		case math.IsNaN(f):
+			return "NaN"
+		case f > 0:
+			return "+Inf"
+		}
+		return "-Inf"
+	case NonFiniteNull:
+		return nil
+	}
+	return f
+}
+
+// objField is a single named field of a struct object
+type objField struct {
+	name string
+	v    interface{}
+}
+
+// orderedObj is a struct object that marshals to a json object keeping
+// the declared order of its fields
+type orderedObj []objField
+
+// obj returns the fields as a map unless the options ask for field order
+func (fs orderedObj) obj(o objOpts) interface{} {
+	if o.ordered {
+		return fs
+	}
+	ma := make(map[string]interface{}, len(fs))
+	for _, f := range fs {
+		ma[f.name] = f.v
+	}
+	return ma
+}
+
+// MarshalJSON writes the fields as a json object in order
+func (fs orderedObj) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range fs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// compactJSON renders o as compact json if it can, as objs usually are json
+// compatible, falling back to fmt if not
+func compactJSON(o interface{}) string {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Sprintf("%v", o)
+	}
+	return string(b)
+}
@@ -0,0 +1,121 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestOrderedJSON(t *testing.T) {
+	type inner struct {
+		Zed   int
+		Alpha string
+	}
+	type thing struct {
+		Name   string
+		Age    int
+		Inner  inner
+		Absent *inner
+		Blob   []byte
+	}
+	d := scanOne(t, thing{Name: "bob", Age: 3, Inner: inner{Zed: 1, Alpha: "a"}})
+
+	b, err := d.OrderedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expOrdered {
+		t.Error("did not get the ordered json")
+		t.Log(string(b))
+	}
+
+	d.SetAbsent(AbsentOmit)
+	b, err = d.OrderedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expOrderedOmit {
+		t.Error("did not get the ordered json with absent fields omitted")
+		t.Log(string(b))
+	}
+}
+
+var expOrdered = `{
+  "Name": "bob",
+  "Age": 3,
+  "Inner": {
+    "Zed": 1,
+    "Alpha": "a"
+  },
+  "Absent": {
+    "Zed": 0,
+    "Alpha": ""
+  },
+  "Blob": null
+}`
+
+var expOrderedOmit = `{
+  "Name": "bob",
+  "Age": 3,
+  "Inner": {
+    "Zed": 1,
+    "Alpha": "a"
+  }
+}`
+
+func TestJSONNumberOptions(t *testing.T) {
+	type nums struct {
+		Small  int64
+		Big    int64
+		Neg    int64
+		UBig   uint64
+		NaN    float64
+		PosInf float64
+		NegInf float64
+	}
+	in := nums{
+		Small:  1 << 53,
+		Big:    1<<53 + 1,
+		Neg:    -(1<<62 + 3),
+		UBig:   1<<64 - 1,
+		NaN:    math.NaN(),
+		PosInf: math.Inf(1),
+		NegInf: math.Inf(-1),
+	}
+
+	d := scanOne(t, in)
+	if _, err := d.JSON(); err == nil {
+		t.Error("non finite floats should fail by default")
+	}
+
+	d.SetBigIntStrings(true)
+	d.SetNonFinite(NonFiniteString)
+	b, err := d.OrderedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"Small":9007199254740992,"Big":"9007199254740993","Neg":"-4611686018427387907","UBig":"18446744073709551615","NaN":"NaN","PosInf":"+Inf","NegInf":"-Inf"}`
+	if got := compact(t, b); got != exp {
+		t.Error("bad string numbers", got)
+	}
+
+	d.SetNonFinite(NonFiniteNull)
+	b, err = d.OrderedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = `{"Small":9007199254740992,"Big":"9007199254740993","Neg":"-4611686018427387907","UBig":"18446744073709551615","NaN":null,"PosInf":null,"NegInf":null}`
+	if got := compact(t, b); got != exp {
+		t.Error("bad null numbers", got)
+	}
+}
+
+func compact(t *testing.T, b []byte) string {
+	t.Helper()
+	var cb bytes.Buffer
+	if err := json.Compact(&cb, b); err != nil {
+		t.Fatal(err)
+	}
+	return cb.String()
+}
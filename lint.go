@@ -0,0 +1,302 @@
+package goblin
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Rule is a gob encoding rule checked by Lint
+type Rule int
+
+const (
+	// NonMinimalUint is an unsigned int in more bytes than it needs, which gob never writes
+	NonMinimalUint Rule = iota
+	// FieldDelta is a field delta beyond the fields of the struct, or a non zero singleton delta
+	FieldDelta
+	// NoTerminator is a struct without the 0 delta that ends its fields
+	NoTerminator
+	// CountMismatch is a length or count that the rest of the message is too short
+	// for, or an array length that is not the length of its type
+	CountMismatch
+	// TrailingBytes are bytes left in a message after its value
+	TrailingBytes
+	// ShortMessage is a message that ends part way through its value
+	ShortMessage
+	// UndefinedType is a type id used before it is defined
+	UndefinedType
+	// DuplicateType is a type id that is defined again, which is also how a
+	// concatenated stream starts, see Segment
+	DuplicateType
+	// BadType is a type definition that can not be decoded
+	BadType
+)
+
+var ruleNames = []string{
+	NonMinimalUint: "non minimal uint",
+	FieldDelta:     "field delta out of range",
+	NoTerminator:   "no struct terminator",
+	CountMismatch:  "count mismatch",
+	TrailingBytes:  "trailing bytes",
+	ShortMessage:   "short message",
+	UndefinedType:  "undefined type",
+	DuplicateType:  "duplicate type",
+	BadType:        "bad type",
+}
+
+func (r Rule) String() string {
+	if r < 0 || int(r) >= len(ruleNames) {
+		return "unknown"
+	}
+	return ruleNames[r]
+}
+
+// Violation is a break of the gob encoding rules found by Lint
+type Violation struct {
+	Offset int64  // the byte offset in the stream
+	Path   string // the dotted path to the value, [n] for the nth element or map entry
+	Rule   Rule   // the rule broken
+	Detail string
+}
+
+func (v Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "."
+	}
+	return fmt.Sprintf("offset %d: %s: %s: %s", v.Offset, path, v.Rule, v.Detail)
+}
+
+// Lint reads the whole gob stream in r checking each message against the
+// rules that encoding/gob writes by, and would reject, or that show the writer
+// is buggy. A message is checked up to its first violation that means the
+// rest of it can not be followed. The error is for streams that can not be
+// split into messages, and the violations found before it are returned too.
+// The types of streams concatenated in r are scoped to each stream as they
+// are for Scan, see Segment, with each type that is defined again reported.
+func Lint(r io.Reader) ([]Violation, error) {
+	d := New(r)
+	d.initTypes()
+	l := &linter{d: d}
+	for {
+		if err := d.getBuf(); err != nil {
+			return l.vs, fmt.Errorf("at offset %d: %v", d.off, err)
+		}
+		if len(d.b) == 0 {
+			return l.vs, nil
+		}
+		l.message(d.b)
+	}
+}
+
+// linter checks the messages of a stream, following the types it defines
+type linter struct {
+	d    *decoder
+	msg  []byte // the message being checked
+	b    []byte // the rest of it
+	path []string
+	vs   []Violation
+}
+
+func (l *linter) report(off int, r Rule, format string, args ...interface{}) {
+	l.vs = append(l.vs, Violation{
+		Offset: l.d.msgOff + int64(off),
+		Path:   strings.Join(l.path, "."),
+		Rule:   r,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// at returns the offset of the next byte in the message
+func (l *linter) at() int {
+	return len(l.msg) - len(l.b)
+}
+
+// uint reads an unsigned int, reporting any that are not minimally encoded.
+// False if there are not enough bytes.
+func (l *linter) uint() (uint64, bool) {
+	off := l.at()
+	n, x, err := decodeUint(l.b)
+	if err != nil {
+		l.report(off, ShortMessage, "%v", err)
+		return 0, false
+	}
+	if n > 1 && (x <= 0x7f || l.b[1] == 0) {
+		l.report(off, NonMinimalUint, "%d in %d bytes", x, n)
+	}
+	l.b = l.b[n:]
+	return x, true
+}
+
+func (l *linter) int() (int64, bool) {
+	x, ok := l.uint()
+	if x&1 != 0 {
+		return ^int64(x >> 1), ok
+	}
+	return int64(x >> 1), ok
+}
+
+// message checks the whole message msg
+func (l *linter) message(msg []byte) {
+	l.msg, l.b, l.path = msg, msg, l.path[:0]
+	if _, ok := l.uint(); !ok {
+		return
+	}
+	off := l.at()
+	typ, ok := l.int()
+	if !ok {
+		return
+	}
+	if typ < 0 {
+		l.typeDef(-typeID(typ), off)
+		return
+	}
+
+	// types defined after a value may start a new stream, as for Scan
+	l.d.endRun()
+	id := typeID(typ)
+	if _, ok := l.d.types[id]; !ok && id >= minUserType {
+		l.report(off, UndefinedType, "type id %d", id)
+		return
+	}
+	p, err := l.d.plan(id)
+	if err != nil {
+		l.report(off, UndefinedType, "%v", err)
+		return
+	}
+	if p.t != tStruct {
+		// the singleton delta
+		off = l.at()
+		delta, ok := l.uint()
+		if !ok {
+			return
+		}
+		if delta != 0 {
+			l.report(off, FieldDelta, "singleton delta %d", delta)
+		}
+	}
+	if l.value(p) {
+		l.trailing()
+	}
+}
+
+// typeDef checks the definition of type id and adds it to the types
+func (l *linter) typeDef(id typeID, off int) {
+	p, err := l.d.plan(tWireType)
+	if err != nil {
+		l.report(off, BadType, "%v", err)
+		return
+	}
+	if !l.value(p) {
+		return
+	}
+	l.trailing()
+	if _, ok := l.d.types[id]; ok {
+		l.report(off, DuplicateType, "type id %d", id)
+	}
+	l.d.b = l.msg
+	if _, err := l.d.decodeType(); err != nil {
+		l.report(off, BadType, "%v", err)
+	}
+}
+
+func (l *linter) trailing() {
+	if len(l.b) > 0 {
+		l.report(l.at(), TrailingBytes, "%d bytes", len(l.b))
+	}
+}
+
+// count reads a length or count of things that take at least a byte each
+func (l *linter) count(what string) (int, bool) {
+	off := l.at()
+	n, ok := l.uint()
+	if !ok {
+		return 0, false
+	}
+	if n > uint64(len(l.b)) {
+		l.report(off, CountMismatch, "%s %d with %d bytes left", what, n, len(l.b))
+		return 0, false
+	}
+	return int(n), true
+}
+
+// value checks a value of the plan's type, false if the rest of the message
+// can not be followed
+func (l *linter) value(p *plan) bool {
+	switch p.t {
+	case tBool, tInt, tUint, tFloat:
+		_, ok := l.uint()
+		return ok
+	case tBytes, tString:
+		n, ok := l.count("length")
+		l.b = l.b[n:]
+		return ok
+	case tSlice:
+		off := l.at()
+		n, ok := l.count("count")
+		if !ok {
+			return false
+		}
+		if p.array && n != p.length {
+			l.report(off, CountMismatch, "array length %d of a [%d] array", n, p.length)
+		}
+		return l.elements(n, func() bool { return l.value(p.elem) })
+	case tMap:
+		n, ok := l.count("count")
+		if !ok {
+			return false
+		}
+		return l.elements(n, func() bool { return l.value(p.key) && l.value(p.elem) })
+	case tStruct:
+		return l.fields(p)
+	}
+	return true
+}
+
+// elements checks the n elements or map entries with el, pathing them by index
+func (l *linter) elements(n int, el func() bool) bool {
+	level := len(l.path) - 1
+	if level < 0 {
+		l.path = append(l.path, "")
+		level = 0
+	}
+	last := l.path[level]
+	defer func() { l.path[level] = last }()
+	for i := 0; i < n; i++ {
+		l.path[level] = last + "[" + strconv.Itoa(i) + "]"
+		if !el() {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *linter) fields(p *plan) bool {
+	fc := -1
+	for {
+		off := l.at()
+		if len(l.b) == 0 {
+			l.report(off, NoTerminator, "after %d of %d fields", fc+1, len(p.fields))
+			return false
+		}
+		delta, ok := l.uint()
+		if !ok {
+			return false
+		}
+		if delta == 0 {
+			return true
+		}
+		if delta > uint64(len(p.fields)-1-fc) {
+			l.report(off, FieldDelta, "delta %d after field %d of %d", delta, fc, len(p.fields))
+			return false
+		}
+		fc += int(delta)
+		l.path = append(l.path, p.fields[fc].name)
+		ok = l.value(p.fields[fc].p)
+		l.path = l.path[:len(l.path)-1]
+		if !ok {
+			return false
+		}
+	}
+}
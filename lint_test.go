@@ -0,0 +1,136 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	type rec struct {
+		Name string
+		N    []int
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(rec{Name: "a", N: []int{1}}); err != nil {
+		t.Fatal("shame", err)
+	}
+	stream := buf.Bytes()
+	ix, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, value := stream[:ix.Values[0]], stream[ix.Values[0]:]
+	vs, err := Lint(bytes.NewReader(stream))
+	if err != nil || len(vs) != 0 {
+		t.Fatal("expected a clean stream", vs, err)
+	}
+
+	// the body of the value after its one byte length and the type id is the
+	// Name delta, length and byte, the N delta, count and element, and the terminator
+	head, body := value[1:len(value)-7], value[len(value)-7:]
+	if !bytes.Equal(body, []byte{1, 1, 'a', 1, 1, 2, 0}) {
+		t.Fatalf("unexpected encoding % x", body)
+	}
+	msg := func(b ...byte) []byte {
+		m := append([]byte{byte(len(head) + len(b))}, head...)
+		return append(m, b...)
+	}
+	// the offset of the body
+	off := int64(len(types) + 1 + len(head))
+
+	cases := []struct {
+		name   string
+		values []byte
+		exp    []Violation
+	}{
+		{
+			name:   "non minimal",
+			values: msg(1, 1, 'a', 1, 0xff, 1, 2, 0),
+			exp:    []Violation{{Offset: off + 4, Path: "N", Rule: NonMinimalUint, Detail: "1 in 2 bytes"}},
+		},
+		{
+			name:   "delta",
+			values: msg(1, 1, 'a', 2, 1, 2, 0),
+			exp:    []Violation{{Offset: off + 3, Rule: FieldDelta, Detail: "delta 2 after field 0 of 2"}},
+		},
+		{
+			name:   "terminator",
+			values: msg(1, 1, 'a', 1, 1, 2),
+			exp:    []Violation{{Offset: off + 6, Rule: NoTerminator, Detail: "after 2 of 2 fields"}},
+		},
+		{
+			name:   "count",
+			values: msg(1, 1, 'a', 1, 9, 2, 0),
+			exp:    []Violation{{Offset: off + 4, Path: "N", Rule: CountMismatch, Detail: "count 9 with 2 bytes left"}},
+		},
+		{
+			name:   "trailing",
+			values: msg(1, 1, 'a', 1, 1, 2, 0, 0),
+			exp:    []Violation{{Offset: off + 7, Rule: TrailingBytes, Detail: "1 bytes"}},
+		},
+		{
+			name:   "undefined",
+			values: []byte{2, 100, 0}, // type id 50
+			exp:    []Violation{{Offset: int64(len(types)) + 1, Rule: UndefinedType, Detail: "type id 50"}},
+		},
+	}
+	for _, c := range cases {
+		s := append(append([]byte{}, types...), c.values...)
+		vs, err := Lint(bytes.NewReader(s))
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if !reflect.DeepEqual(vs, c.exp) {
+			t.Errorf("%s: got %v", c.name, vs)
+		}
+	}
+
+	// concatenated streams define the same ids again
+	vs, err = Lint(bytes.NewReader(append(append([]byte{}, stream...), stream...)))
+	var exp []Violation
+	for _, off := range ix.Types {
+		typ, err := msgType(stream[off:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp = append(exp, Violation{Offset: int64(len(stream)) + off + 1, Rule: DuplicateType, Detail: fmt.Sprintf("type id %d", -typ)})
+	}
+	if err != nil || !reflect.DeepEqual(vs, exp) {
+		t.Errorf("got %v %v", vs, err)
+	}
+
+	// the types of a concatenated stream are its own, with the new id of the
+	// outer type sent before the redefined one
+	type outer struct {
+		In struct{ Size float64 }
+	}
+	a, b := redefinedStreams(t, rec{Name: "a"}, outer{})
+	bix, err := BuildIndex(bytes.NewReader(b))
+	if err != nil || len(bix.Types) != 2 {
+		t.Fatal(bix, err)
+	}
+	vs, err = Lint(bytes.NewReader(append(a, b...)))
+	typ, _ := msgType(b[bix.Types[1]:])
+	exp = []Violation{{Offset: int64(len(a)) + bix.Types[1] + 1, Rule: DuplicateType, Detail: fmt.Sprintf("type id %d", -typ)}}
+	if err != nil || !reflect.DeepEqual(vs, exp) {
+		t.Errorf("got %v %v", vs, err)
+	}
+
+	// a truncated stream can not be split into messages
+	if _, err := Lint(bytes.NewReader(stream[:len(stream)-1])); err == nil {
+		t.Error("expected an error")
+	}
+
+	// nor can one with a length that is negative as an int, or too big
+	for _, prefix := range [][]byte{
+		{0xf8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf7},
+		{0xfc, 0x7f, 0xff, 0xff, 0xff},
+	} {
+		if _, err := Lint(bytes.NewReader(append(prefix, stream...))); err == nil {
+			t.Errorf("% x: expected an error", prefix)
+		}
+	}
+}
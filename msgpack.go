@@ -0,0 +1,118 @@
+package goblin
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// msgpackEncoder writes the MessagePack format, using the smallest encoding
+// of each int and uint within their signed and unsigned families
+type msgpackEncoder struct {
+	b []byte
+}
+
+func (e *msgpackEncoder) null() {
+	e.b = append(e.b, 0xc0)
+}
+
+func (e *msgpackEncoder) bool(b bool) {
+	if b {
+		e.b = append(e.b, 0xc3)
+		return
+	}
+	e.b = append(e.b, 0xc2)
+}
+
+func (e *msgpackEncoder) int(i int64) {
+	switch {
+	case i >= -32 && i <= 127:
+		e.b = append(e.b, byte(i)) // positive and negative fixint
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		e.b = append(e.b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		e.b = append(e.b, 0xd1)
+		e.b = appendUint16(e.b, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		e.b = append(e.b, 0xd2)
+		e.b = appendUint32(e.b, uint32(i))
+	default:
+		e.b = append(e.b, 0xd3)
+		e.b = appendUint64(e.b, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) uint(u uint64) {
+	switch {
+	case u <= 127:
+		e.b = append(e.b, byte(u))
+	case u <= math.MaxUint8:
+		e.b = append(e.b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.b = append(e.b, 0xcd)
+		e.b = appendUint16(e.b, uint16(u))
+	case u <= math.MaxUint32:
+		e.b = append(e.b, 0xce)
+		e.b = appendUint32(e.b, uint32(u))
+	default:
+		e.b = append(e.b, 0xcf)
+		e.b = appendUint64(e.b, u)
+	}
+}
+
+func (e *msgpackEncoder) float(f float64) {
+	e.b = append(e.b, 0xcb)
+	e.b = appendUint64(e.b, math.Float64bits(f))
+}
+
+func (e *msgpackEncoder) bytes(b []byte) {
+	e.head(len(b), 0, 0, 0xc4, 0xc5, 0xc6)
+	e.b = append(e.b, b...)
+}
+
+func (e *msgpackEncoder) string(s string) {
+	e.head(len(s), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	e.b = append(e.b, s...)
+}
+
+func (e *msgpackEncoder) array(n int) {
+	e.head(n, 0x90, 16, 0, 0xdc, 0xdd)
+}
+
+func (e *msgpackEncoder) mapOf(n int) {
+	e.head(n, 0x80, 16, 0, 0xde, 0xdf)
+}
+
+// head writes the length n in the smallest of the formats given, those that
+// are 0 do not exist for the type. fix holds lengths below fixLen in its low bits.
+func (e *msgpackEncoder) head(n int, fix byte, fixLen int, b8, b16, b32 byte) {
+	switch {
+	case n < fixLen:
+		e.b = append(e.b, fix|byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		e.b = append(e.b, b8, byte(n))
+	case n <= math.MaxUint16:
+		e.b = append(e.b, b16)
+		e.b = appendUint16(e.b, uint16(n))
+	default:
+		e.b = append(e.b, b32)
+		e.b = appendUint32(e.b, uint32(n))
+	}
+}
+
+func appendUint16(b []byte, u uint16) []byte {
+	var a [2]byte
+	binary.BigEndian.PutUint16(a[:], u)
+	return append(b, a[:]...)
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	var a [4]byte
+	binary.BigEndian.PutUint32(a[:], u)
+	return append(b, a[:]...)
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	var a [8]byte
+	binary.BigEndian.PutUint64(a[:], u)
+	return append(b, a[:]...)
+}
@@ -0,0 +1,185 @@
+package goblin
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Result is a value decoded by Parallel
+type Result struct {
+	N       int         // the position of the value counting from the first one Parallel decoded
+	Segment int         // which of the concatenated streams the value came from, see Segment
+	Value   Value       // the decoded value
+	Out     interface{} // what the func passed to Parallel returned for the value
+	Err     error       // any error decoding the value, or returned by the func
+
+	filtered bool // the value did not pass the filter so is not delivered
+}
+
+// job is a single value message to be decoded by a worker
+type job struct {
+	n     int
+	msg   []byte
+	types map[typeID]val // the types as they were when the message was read
+	gen   int            // which version of the types they are
+	seg   int            // the stream segment of the message
+	res   chan Result
+}
+
+// Parallel decodes the rest of the stream on workers goroutines, or one per
+// cpu if workers < 1, while a single goroutine reads the stream and splits it
+// into messages. If fn is not nil it is called with each value on the worker
+// goroutines, so the costly part of converting values, to json for instance,
+// happens in parallel as well, and its results are put in Result.Out.
+//
+// Results are delivered in stream order on the returned channel, which is
+// closed at the end of the stream, after the first Result with an error, or
+// once done is closed. Only the values that pass the filter are delivered, see
+// SetFilter, with N still counting those that did not. Lenient and partial
+// decoding are not done, the first message that fails to decode ends the
+// results.
+//
+// The results must be read until the channel is closed, or done closed, to
+// stop the goroutines, which are left blocked otherwise, so done must not be
+// nil if the reading might stop early. The decoder must not be used while
+// Parallel is running.
+func (d *decoder) Parallel(workers int, fn func(Value) (interface{}, error), done <-chan struct{}) <-chan Result {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	out := make(chan Result)
+	jobs := make(chan *job)
+	order := make(chan *job, workers*2) // the jobs in stream order
+	stop := make(chan struct{})
+
+	go d.split(jobs, order, stop)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			// each worker has a decoder of its own to keep its plans
+			var w *decoder
+			for j := range jobs {
+				if w == nil || w.gen != j.gen {
+					w = &decoder{
+						types:    j.types,
+						gen:      j.gen,
+						opts:     d.opts,
+						hints:    d.hints,
+						zeroCopy: d.zeroCopy,
+						filter:   d.filter,
+					}
+				}
+				j.res <- w.decodeJob(j, fn)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(stop)
+		defer close(out)
+		for j := range order {
+			r := <-j.res
+			if r.filtered {
+				continue
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			select {
+			case out <- r:
+			case <-done:
+				return
+			}
+			if r.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// split reads the messages from the stream, sending the value messages as jobs
+// to the workers and in order to be collected, until the end of the stream or stop
+func (d *decoder) split(jobs, order chan<- *job, stop <-chan struct{}) {
+	defer close(order)
+	defer close(jobs)
+
+	var err error
+	if len(d.types) == 0 {
+		err = d.setupTypes() // which leaves the first value in d.b
+	} else {
+		err = d.getBuf()
+	}
+	for n := 0; ; {
+		if err == nil && len(d.b) == 0 {
+			return // the normal end
+		}
+		if err == nil {
+			var typ int64
+			typ, err = msgType(d.b)
+			if err == nil && typ < 0 {
+				// the workers are reading the types so add the new type to a copy
+				types := make(map[typeID]val, len(d.types)+1)
+				for k, v := range d.types {
+					types[k] = v
+				}
+				d.types = types
+				d.gen++
+				_, err = d.decodeType()
+				if err == nil {
+					err = d.getBuf()
+				}
+				continue
+			}
+		}
+
+		d.endRun()
+		j := &job{
+			n:     n,
+			msg:   append([]byte(nil), d.b...), // as d.b is reused
+			types: d.types,
+			gen:   d.gen,
+			seg:   d.segment,
+			res:   make(chan Result, 1),
+		}
+		if err != nil {
+			j.res <- Result{N: n, Segment: d.segment, Err: fmt.Errorf("at offset %d: %v", d.msgOff, err)}
+		}
+		select {
+		case order <- j:
+		case <-stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+		select {
+		case jobs <- j:
+		case <-stop:
+			return
+		}
+		n++
+		err = d.getBuf()
+	}
+}
+
+// decodeJob decodes the value message of the job, d is the worker's decoder
+func (d *decoder) decodeJob(j *job, fn func(Value) (interface{}, error)) Result {
+	d.b = j.msg
+	r := Result{N: j.n, Segment: j.seg}
+	if r.Err = d.decodeData(); r.Err != nil {
+		return r
+	}
+	r.Value = d.Value()
+	if d.filter != nil && !d.filter(r.Value) {
+		r.filtered = true
+		return r
+	}
+	if fn != nil {
+		r.Out, r.Err = fn(r.Value)
+	}
+	return r
+}
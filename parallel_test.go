@@ -0,0 +1,98 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	type record struct {
+		N     int
+		Name  string
+		Sizes []float64
+	}
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < 200; i++ {
+		r := record{N: i, Name: "rec", Sizes: []float64{float64(i) / 3}}
+		if err := enc.Encode(r); err != nil {
+			t.Fatal("shame", err)
+		}
+	}
+	stream := buf.Bytes()
+
+	// the sequential json to compare against
+	var exp [][]byte
+	d := New(bytes.NewReader(stream))
+	for d.Scan() {
+		b, _ := d.JSON()
+		exp = append(exp, b)
+	}
+
+	toJSON := func(v Value) (interface{}, error) {
+		return json.MarshalIndent(v.Obj(), "", "  ")
+	}
+	n := 0
+	for r := range New(bytes.NewReader(stream)).Parallel(4, toJSON, nil) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.N != n {
+			t.Fatalf("out of order got %d expected %d", r.N, n)
+		}
+		if !bytes.Equal(r.Out.([]byte), exp[n]) {
+			t.Errorf("%d) bad json %s", n, r.Out)
+		}
+		if nv, _ := r.Value.Field("N"); nv.ToInt() != int64(n) {
+			t.Errorf("%d) bad value", n)
+		}
+		n++
+	}
+	if n != 200 {
+		t.Error("did not get all the values", n)
+	}
+
+	// only the values that pass the filter are delivered
+	f, err := ParseFilter("N >= 190")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pd := New(bytes.NewReader(stream))
+	pd.SetFilter(f)
+	n = 190
+	for r := range pd.Parallel(4, toJSON, nil) {
+		if r.Err != nil || r.N != n {
+			t.Fatalf("got %d %v expected %d", r.N, r.Err, n)
+		}
+		n++
+	}
+	if n != 200 {
+		t.Error("did not get the filtered values", n)
+	}
+
+	// stopping early closes the results
+	done := make(chan struct{})
+	n = 0
+	for range New(bytes.NewReader(stream)).Parallel(0, nil, done) {
+		n++
+		if n == 3 {
+			close(done)
+		}
+	}
+	if n > 4 {
+		t.Error("should have stopped soon after done", n)
+	}
+
+	// errors end the results
+	var errs int
+	for r := range New(bytes.NewReader(stream[:len(stream)-3])).Parallel(2, nil, nil) {
+		if r.Err != nil {
+			errs++
+		}
+	}
+	if errs != 1 {
+		t.Error("expected an error from the truncated stream")
+	}
+}
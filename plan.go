@@ -0,0 +1,369 @@
+package goblin
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// the bool data, shared as vals are never changed once decoded
+var (
+	trueData  = []byte{1}
+	falseData = []byte{0}
+)
+
+// plan is the compiled decoder of a type. Each type is compiled once, on first
+// use, so that decoding its values does not need to look anything up in the
+// types index, similar to the decEngine of encoding/gob.
+type plan struct {
+	op func(d *decoder, p *plan, v *val) error // decodes a value of the type into v
+
+	t      typeID      // the primitive type id of the values
+	id     typeID      // the user type id, if it is one
+	array  bool        // for arrays
+	elemID typeID      // the element type of slices and arrays, and value type of maps
+	keyID  typeID      // the key type of maps
+	elem   *plan       // decodes the elements of slices, arrays and maps
+	key    *plan       // decodes the keys of maps
+	length int         // the length of arrays
+	fields []fieldPlan // the fields of structs in order
+
+	zero    *val // the zero value of the type, made on first use
+	zeroing bool // making the zero value, for recursive types
+}
+
+// fieldPlan is the name and plan of a struct field
+type fieldPlan struct {
+	name string
+	p    *plan
+	ptr  bool // declared as a pointer in the type hints
+}
+
+// plan returns the compiled plan for the type id, compiling it if need be
+func (d *decoder) plan(id typeID) (*plan, error) {
+	if p, ok := d.plans[id]; ok {
+		return p, nil
+	}
+	if d.plans == nil {
+		d.plans = map[typeID]*plan{}
+	}
+	p := &plan{}
+	// add it before compiling so recursive types find it
+	d.plans[id] = p
+	var err error
+	switch {
+	case id >= minUserType:
+		err = d.compileWire(p, id)
+	case id < tSlice:
+		p.t = id
+		err = p.compilePrimitive()
+	default:
+		// the types that describe types are in the index as vals
+		t, ok := d.types[id]
+		if !ok {
+			err = fmt.Errorf("found type id that is not in index: %d", id)
+			break
+		}
+		err = d.compileVal(p, t)
+	}
+	if err != nil {
+		delete(d.plans, id)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *plan) compilePrimitive() error {
+	switch p.t {
+	case tBool:
+		p.op = decBool
+	case tInt, tUint, tFloat:
+		p.op = decNumber
+	case tBytes, tString:
+		p.op = decBytes
+	default:
+		return fmt.Errorf("found type id that is not supported: %d", p.t)
+	}
+	return nil
+}
+
+// compileWire compiles the user type from its wireType definition
+func (d *decoder) compileWire(p *plan, id typeID) error {
+	w, def, ok := d.wireDef(id)
+	if !ok {
+		return fmt.Errorf("found type id that is not in index: %d", id)
+	}
+	p.id = id
+	var err error
+	switch w {
+	case wireArray:
+		p.t = tSlice
+		p.op = decSlice
+		p.array = true
+		p.length = defLen(def)
+		p.elemID = defElem(w, def)
+		p.elem, err = d.plan(p.elemID)
+	case wireSlice:
+		p.t = tSlice
+		p.op = decSlice
+		p.elemID = defElem(w, def)
+		p.elem, err = d.plan(p.elemID)
+	case wireMap:
+		p.t = tMap
+		p.op = decMap
+		p.keyID = defKey(def)
+		p.elemID = defElem(w, def)
+		if p.key, err = d.plan(p.keyID); err == nil {
+			p.elem, err = d.plan(p.elemID)
+		}
+	case wireStruct:
+		p.t = tStruct
+		p.op = decStruct
+		name := d.idToType(int(id))
+		for _, f := range defFields(def) {
+			fp, err := d.plan(f.id)
+			if err != nil {
+				return err
+			}
+			ptr := d.hints.ptr(name, f.name, d.wireKind(f.id))
+			p.fields = append(p.fields, fieldPlan{name: f.name, p: fp, ptr: ptr})
+		}
+	}
+	return err
+}
+
+// compileVal compiles the template val of one of the types that describe types
+func (d *decoder) compileVal(p *plan, v val) error {
+	p.t = v.t
+	var err error
+	switch v.t {
+	case tSlice:
+		p.op = decSlice
+		p.elemID = v.sl.t
+		p.elem, err = d.plan(v.sl.t)
+	case tMap:
+		p.op = decMap
+		p.keyID, p.elemID = v.ma.kt, v.ma.vt
+		if p.key, err = d.plan(v.ma.kt); err == nil {
+			p.elem, err = d.plan(v.ma.vt)
+		}
+	case tStruct:
+		p.op = decStruct
+		for _, f := range v.st {
+			fp := &plan{}
+			if err := d.compileVal(fp, f.v); err != nil {
+				return err
+			}
+			p.fields = append(p.fields, fieldPlan{name: f.name, p: fp})
+		}
+	default:
+		err = p.compilePrimitive()
+	}
+	return err
+}
+
+// zeroVal returns the zero value of the plan's type, as the value of absent
+// fields. It is shared so must not be changed.
+func (p *plan) zeroVal() val {
+	if p.zero != nil {
+		return *p.zero
+	}
+	z := val{
+		t:  p.t,
+		id: p.id,
+	}
+	if p.zeroing {
+		return z // a recursive type so stop at an empty value
+	}
+	p.zeroing = true
+	switch p.t {
+	case tSlice:
+		z.sl.t = p.elemID
+		z.sl.array = p.array
+		if p.array {
+			// arrays always have their full length of zero elements
+			z.sl.els = make([]val, p.length)
+			for i := range z.sl.els {
+				z.sl.els[i] = p.elem.zeroVal()
+			}
+		}
+	case tMap:
+		z.ma.kt, z.ma.vt = p.keyID, p.elemID
+	case tStruct:
+		z.st = make(structv, len(p.fields))
+		for i, f := range p.fields {
+			// a field of a type that is being zeroed is recursive so a pointer
+			nilZero := f.ptr || f.p.zeroing
+			z.st[i] = field{name: f.name, v: f.p.zeroVal(), nilZero: nilZero}
+		}
+	}
+	p.zeroing = false
+	p.zero = &z
+	return z
+}
+
+// run decodes a value of the plan's type into v
+func (p *plan) run(d *decoder, v *val) error {
+	return p.op(d, p, v)
+}
+
+func decBool(d *decoder, p *plan, v *val) error {
+	v.t = tBool
+	if len(d.b) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if d.decodeBool() {
+		v.da = trueData
+	} else {
+		v.da = falseData
+	}
+	return nil
+}
+
+func decNumber(d *decoder, p *plan, v *val) error {
+	v.t = p.t
+	iv, err := d.decodeUint()
+	if err != nil {
+		return err
+	}
+	v.nu = iv
+	return nil
+}
+
+func decBytes(d *decoder, p *plan, v *val) error {
+	v.t = p.t
+	return d.decodeBytes(v)
+}
+
+func decSlice(d *decoder, p *plan, v *val) error {
+	v.t = tSlice
+	v.id = p.id
+	v.sl.t = p.elemID
+	v.sl.array = p.array
+	ui, err := d.decodeUint()
+	if err != nil {
+		return err
+	}
+	// every element takes at least a byte, so corrupt counts do not allocate
+	n, err := d.elemCount(ui)
+	if err != nil {
+		return err
+	}
+	v.sl.els = make([]val, 0, n)
+	d.path = append(d.path, "")
+	err = decSliceEls(d, p, v, ui)
+	d.path = d.path[:len(d.path)-1]
+	return err
+}
+
+func decSliceEls(d *decoder, p *plan, v *val, count uint64) error {
+	for i := uint64(0); i < count; i++ {
+		d.path[len(d.path)-1] = strconv.FormatUint(i, 10)
+		v.sl.els = append(v.sl.els, val{})
+		if err := p.elem.op(d, p.elem, &v.sl.els[i]); err != nil {
+			// keep what there is of a truncated slice
+			d.cutShort(d.path)
+			return err
+		}
+	}
+	return nil
+}
+
+// elemCount returns how many of the count elements the rest of the message
+// can hold, as each takes at least a byte. That is an error unless the
+// message was truncated, when only the elements that are there are decoded.
+func (d *decoder) elemCount(count uint64) (int, error) {
+	if count <= uint64(len(d.b)) {
+		return int(count), nil
+	}
+	if !d.cut {
+		return 0, fmt.Errorf("%s %d elements are beyond the end of the message", d.paths(), count)
+	}
+	return len(d.b), nil
+}
+
+func decMap(d *decoder, p *plan, v *val) error {
+	v.t = tMap
+	v.id = p.id
+	v.ma.kt, v.ma.vt = p.keyID, p.elemID
+	d.path = append(d.path, "")
+	err := decMapEls(d, p, v)
+	d.path = d.path[:len(d.path)-1]
+	return err
+}
+
+func decMapEls(d *decoder, p *plan, v *val) error {
+	// get element count
+	ui, err := d.decodeUint()
+	if err != nil {
+		return err
+	}
+	n, err := d.elemCount(ui)
+	if err != nil {
+		return err
+	}
+	v.ma.keys = make([]val, 0, n)
+	v.ma.els = make(map[string]val, n)
+	for i := uint64(0); i < ui; i++ {
+		var k, nv val
+		if err := p.key.op(d, p.key, &k); err != nil {
+			d.cutShort(d.path[:len(d.path)-1])
+			return err
+		}
+		// structs and arrays are keys too, so path them by what they read as
+		ks := k.key()
+		d.path[len(d.path)-1] = ks
+		v.ma.keys = append(v.ma.keys, k)
+		if err := p.elem.op(d, p.elem, &nv); err != nil {
+			// keep what there is of a truncated map
+			v.ma.els[ks] = nv
+			d.cutShort(d.path)
+			return err
+		}
+		v.ma.els[ks] = nv
+	}
+	return nil
+}
+
+func decStruct(d *decoder, p *plan, v *val) error {
+	v.t = tStruct
+	v.id = p.id
+	// start with all fields absent
+	v.st = make(structv, len(p.fields))
+	copy(v.st, p.zeroVal().st)
+	d.path = append(d.path, "")
+	err := decFields(d, p, v)
+	d.path = d.path[:len(d.path)-1]
+	return err
+}
+
+func decFields(d *decoder, p *plan, v *val) error {
+	fc := -1
+	level := len(d.path) - 1
+	for {
+		// get the field delta
+		delta, err := d.decodeUint()
+		if err != nil {
+			d.cutShort(d.path[:level])
+			return err
+		}
+		if delta == 0 { // end of fields with the 0 delta terminator
+			return nil
+		}
+		// compared before adding so that corrupt deltas do not overflow fc
+		if delta > uint64(len(v.st)-1-fc) {
+			return fmt.Errorf("%s bad encoding more fields than the type len: %d expected: %d ", d.paths(), uint64(fc)+delta, len(v.st))
+		}
+		fc += int(delta)
+
+		d.path[level] = v.st[fc].name
+		f := &v.st[fc]
+		f.nonZero = true
+		// the op overwrites the zero value the field starts with
+		fp := p.fields[fc].p
+		if err := fp.op(d, fp, &f.v); err != nil {
+			d.cutShort(d.path)
+			return err
+		}
+	}
+}
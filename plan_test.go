@@ -0,0 +1,24 @@
+package goblin
+
+import "testing"
+
+type node struct {
+	Name string
+	Kids []*node
+	Next *node
+}
+
+func TestRecursiveType(t *testing.T) {
+	d := scanOne(t, node{
+		Name: "root",
+		Kids: []*node{{Name: "a"}, {Name: "b", Next: &node{Name: "c"}}},
+	})
+	b, err := d.OrderedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"Name":"root","Kids":[{"Name":"a","Kids":null,"Next":null},{"Name":"b","Kids":null,"Next":{"Name":"c","Kids":null,"Next":null}}],"Next":null}`
+	if got := compact(t, b); got != exp {
+		t.Error("bad recursive json", got)
+	}
+}
@@ -0,0 +1,93 @@
+package goblin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Schema is the type definition messages of a gob stream, as gob sent them,
+// which is all a decoder needs to decode the value messages that follow them.
+// It is plain bytes so it can be stored and read back as is, see NewWithSchema.
+type Schema []byte
+
+// Schema returns the type messages the decoder has read so far. The first
+// Scan reads the types of the first value.
+func (d *decoder) Schema() Schema {
+	return append(Schema(nil), d.schema...)
+}
+
+// ExtractSchema reads the whole gob stream in r and returns its type
+// messages, wherever they are in the stream, without decoding any values
+func ExtractSchema(r io.Reader) (Schema, error) {
+	d := New(r)
+	var s Schema
+	for {
+		if err := d.getBuf(); err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", d.off, err)
+		}
+		if len(d.b) == 0 {
+			return s, nil
+		}
+		typ, err := msgType(d.b)
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", d.msgOff, err)
+		}
+		if typ < 0 {
+			s = append(s, d.b...)
+		}
+	}
+}
+
+// NewWithSchema returns a decoder of the gob value messages in r, which are
+// decoded with the types in s, as if r followed them in a single stream.
+// Single messages can also be decoded with DecodeMessage, for which r can be
+// nil, though then Scan has no stream to read and fails.
+func NewWithSchema(r io.Reader, s Schema) (*decoder, error) {
+	sd := New(bytes.NewReader(s))
+	sd.initTypes()
+	for {
+		if err := sd.getBuf(); err != nil {
+			return nil, fmt.Errorf("schema at offset %d: %v", sd.off, err)
+		}
+		if len(sd.b) == 0 {
+			break
+		}
+		isType, err := sd.decodeType()
+		if err != nil {
+			return nil, fmt.Errorf("schema at offset %d: %v", sd.msgOff, err)
+		}
+		if !isType {
+			return nil, fmt.Errorf("schema at offset %d: found a value message", sd.msgOff)
+		}
+	}
+	d := New(r)
+	d.types = sd.types
+	d.defs = sd.defs
+	d.schema = sd.schema
+	return d, nil
+}
+
+// DecodeMessage decodes msg, a single value message with its length prefix,
+// as gob.Encoder writes for each value once it has sent the types, so that
+// the Value, Obj, JSON and the other renderings of the last Scan return it.
+// With SetZeroCopy the strings and []byte of the value refer to msg.
+func (d *decoder) DecodeMessage(msg []byte) error {
+	d.lastVal = nil
+	d.lastErr = nil
+	if len(d.types) == 0 {
+		return errors.New("can not decode a message without the types, see NewWithSchema")
+	}
+	typ, err := msgType(msg)
+	if err != nil {
+		return err
+	}
+	if typ < 0 {
+		return errors.New("the message is a type definition not a value")
+	}
+	d.b = msg
+	d.cut = false
+	d.lastErr = d.decodeData()
+	return d.lastErr
+}
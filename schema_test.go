@@ -0,0 +1,79 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	type rec struct {
+		Name string
+		Age  int
+	}
+	// the first value has the types in front of it, the rest are bare
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	var msgs [][]byte
+	for i, name := range []string{"a", "b", "c"} {
+		if err := enc.Encode(rec{Name: name, Age: i + 1}); err != nil {
+			t.Fatal("shame", err)
+		}
+		msgs = append(msgs, append([]byte(nil), buf.Bytes()...))
+		buf.Reset()
+	}
+	stream := bytes.Join(msgs, nil)
+
+	s, err := ExtractSchema(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) == 0 || !bytes.HasPrefix(msgs[0], s) {
+		t.Fatalf("bad schema %x", s)
+	}
+	d := New(bytes.NewReader(stream))
+	d.Scan()
+	if !bytes.Equal(d.Schema(), s) {
+		t.Errorf("decoder schema %x", d.Schema())
+	}
+	bare := [][]byte{msgs[0][len(s):], msgs[1], msgs[2]}
+
+	// a stream of bare messages
+	d, err = NewWithSchema(bytes.NewReader(bytes.Join(bare, nil)), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names string
+	for d.Scan() {
+		v, _ := d.Value().Field("Name")
+		names += v.ToString()
+	}
+	if d.Err() != nil || names != "abc" {
+		t.Errorf("got %q %v", names, d.Err())
+	}
+
+	// and one at a time, as from a kv store
+	d, err = NewWithSchema(nil, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DecodeMessage(bare[2]); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.Value().Field("Age"); v.ToInt() != 3 {
+		t.Error("wrong age", v.ToInt())
+	}
+	if err := d.DecodeMessage(bare[1][:len(bare[1])-1]); err == nil {
+		t.Error("expected an error for a short message")
+	}
+	// there is no stream to scan
+	if d.Scan() || d.Err() == nil || d.Skip() || d.Err() == nil {
+		t.Error("expected an error scanning without a reader")
+	}
+	if err := New(nil).DecodeMessage(bare[1]); err == nil {
+		t.Error("expected an error without a schema")
+	}
+	if _, err := NewWithSchema(nil, Schema(msgs[0])); err == nil {
+		t.Error("expected an error for a schema with a value")
+	}
+}
@@ -0,0 +1,167 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TOML returns the result of the last Scan as a TOML document, so it must be
+// a struct or a map. Struct fields are in their declared order, apart from
+// tables which follow the plain keys of their table as TOML needs. TOML has
+// no null so absent fields are left out unless rendered as their zero value,
+// uints beyond the int64 range are strings, and struct and array map keys are
+// quoted compact json.
+func (d *decoder) TOML() ([]byte, error) {
+	if d.lastVal == nil {
+		return nil, errors.New("can not return toml representation of none existant object")
+	}
+	if d.lastVal.t != tStruct && d.lastVal.t != tMap {
+		return nil, fmt.Errorf("can only write a struct or map as toml not %s", kindOf(d.lastVal.t, d.lastVal.sl.array))
+	}
+	t := tomlWriter{o: d.opts}
+	t.table("", d.lastVal)
+	return t.buf.Bytes(), nil
+}
+
+type tomlWriter struct {
+	buf bytes.Buffer
+	o   objOpts
+}
+
+// tomlEntry is a key of a table and its value
+type tomlEntry struct {
+	key string
+	v   *val
+}
+
+// entries returns the fields of a struct that are rendered, or the elements
+// of a map in key order
+func (t *tomlWriter) entries(v *val) []tomlEntry {
+	var es []tomlEntry
+	if v.t == tMap {
+		for _, k := range v.ma.numericKeys() {
+			ks := k.key()
+			e := v.ma.els[ks]
+			es = append(es, tomlEntry{key: ks, v: &e})
+		}
+		return es
+	}
+	for i := range v.st {
+		f := &v.st[i]
+		if f.nonZero || f.absentAs(t.o.absent) == AbsentZero {
+			es = append(es, tomlEntry{key: f.name, v: &f.v})
+		}
+	}
+	return es
+}
+
+// isTable returns true for structs and maps
+func isTable(v *val) bool {
+	return v.t == tStruct || v.t == tMap
+}
+
+// isTableArray returns true for slices and arrays of structs or maps
+func isTableArray(v *val) bool {
+	return v.t == tSlice && len(v.sl.els) > 0 && isTable(&v.sl.els[0])
+}
+
+// table writes the entries of the struct or map v, which has the dotted path
+func (t *tomlWriter) table(path string, v *val) {
+	es := t.entries(v)
+	for _, e := range es {
+		if !isTable(e.v) && !isTableArray(e.v) {
+			fmt.Fprintf(&t.buf, "%s = %s\n", tomlKey(e.key), t.inline(e.v))
+		}
+	}
+	for _, e := range es {
+		p := tomlKey(e.key)
+		if path != "" {
+			p = path + "." + p
+		}
+		switch {
+		case isTable(e.v):
+			t.header("[" + p + "]")
+			t.table(p, e.v)
+		case isTableArray(e.v):
+			for i := range e.v.sl.els {
+				t.header("[[" + p + "]]")
+				t.table(p, &e.v.sl.els[i])
+			}
+		}
+	}
+}
+
+// header starts a table, after a blank line if anything is before it
+func (t *tomlWriter) header(h string) {
+	if t.buf.Len() > 0 {
+		t.buf.WriteByte('\n')
+	}
+	t.buf.WriteString(h)
+	t.buf.WriteByte('\n')
+}
+
+// inline returns v as a TOML value on a single line
+func (t *tomlWriter) inline(v *val) string {
+	switch v.t {
+	case tBool:
+		return strconv.FormatBool(v.ToBool())
+	case tInt:
+		return strconv.FormatInt(v.ToInt(), 10)
+	case tUint:
+		u := v.ToUint()
+		if u > math.MaxInt64 {
+			return quote(strconv.FormatUint(u, 10))
+		}
+		return strconv.FormatUint(u, 10)
+	case tFloat:
+		f := v.ToFloat()
+		switch {
+		case math.IsNaN(f):
+			return "nan"
+		case math.IsInf(f, 1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		}
+		return floatText(f)
+	case tBytes:
+		return quote(base64.StdEncoding.EncodeToString(v.da))
+	case tString:
+		return quote(string(v.da))
+	case tSlice:
+		els := make([]string, len(v.sl.els))
+		for i := range v.sl.els {
+			els[i] = t.inline(&v.sl.els[i])
+		}
+		return "[" + strings.Join(els, ", ") + "]"
+	case tMap, tStruct:
+		es := t.entries(v)
+		if len(es) == 0 {
+			return "{}"
+		}
+		kvs := make([]string, len(es))
+		for i, e := range es {
+			kvs[i] = tomlKey(e.key) + " = " + t.inline(e.v)
+		}
+		return "{ " + strings.Join(kvs, ", ") + " }"
+	}
+	return `""`
+}
+
+// tomlKey returns k bare if it can be, otherwise quoted
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return quote(k)
+		}
+	}
+	return k
+}
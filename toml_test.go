@@ -0,0 +1,55 @@
+package goblin
+
+import "testing"
+
+func TestTOML(t *testing.T) {
+	d := scanOne(t, cfgValue)
+	b, err := d.TOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expTOML {
+		t.Error("did not get the expected toml")
+		t.Log(string(b))
+	}
+
+	d = scanOne(t, []int{1})
+	if _, err := d.TOML(); err == nil {
+		t.Error("expected an error for a slice document")
+	}
+}
+
+var expTOML = `Name = "true"
+Version = 3
+Ratio = 2.0
+Matrix = [[1, 2], [3]]
+Raw = "aGk="
+Missing = ""
+Inf = inf
+
+[[Servers]]
+Host = "a.example"
+Port = 80
+Debug = false
+
+[[Servers]]
+Host = "b: c"
+Port = 81
+Debug = true
+
+[Limits]
+2 = "two"
+10 = "ten"
+
+[Labels]
+"env var" = "x\ny"
+team = "ops"
+
+[Main]
+Host = "main"
+Port = 1
+Debug = false
+
+[Spots]
+"{\"X\":1,\"Y\":2}" = "here"
+`
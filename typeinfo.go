@@ -0,0 +1,106 @@
+package goblin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TypeInfo describes a type sent in the gob stream. Types can be recursive,
+// so following Elem, Key and Fields can lead back to a TypeInfo already seen.
+// The Name is the one gob sent for named types, the type literal, such as
+// []int64, for unnamed slices, arrays and maps, and Type<ID> for unnamed structs.
+type TypeInfo struct {
+	ID     int         // the gob type id, the builtin types have the lowest ids
+	Name   string      // the Go name of the type, as WriteTypes names it
+	Kind   Kind        // the kind of the values
+	Fields []FieldInfo // the fields of a struct in declared order
+	Key    *TypeInfo   // the key type of a map
+	Elem   *TypeInfo   // the element type of a slice, array or map
+	Len    int         // the length of an array
+}
+
+// FieldInfo is a field of a struct type
+type FieldInfo struct {
+	Name string
+	Type *TypeInfo
+}
+
+// String returns the Go type, built from the element types for slices,
+// arrays and maps as GoString does
+func (ti *TypeInfo) String() string {
+	switch ti.Kind {
+	case Slice:
+		return "[]" + ti.Elem.String()
+	case Array:
+		return fmt.Sprintf("[%d]%s", ti.Len, ti.Elem)
+	case Map:
+		return fmt.Sprintf("map[%s]%s", ti.Key, ti.Elem)
+	}
+	return ti.Name
+}
+
+// Types returns the user types the decoder has read so far, in id order.
+// The first Scan reads the types of the first value.
+func (d *decoder) Types() []*TypeInfo {
+	var ids []int
+	for id := range d.types {
+		if id >= minUserType {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	infos := map[typeID]*TypeInfo{}
+	tis := make([]*TypeInfo, len(ids))
+	for i, id := range ids {
+		tis[i] = d.typeInfo(typeID(id), infos)
+	}
+	return tis
+}
+
+// Type returns the type of the value, nil if it is not valid
+func (v Value) Type() *TypeInfo {
+	if v.v == nil || v.d == nil {
+		return nil
+	}
+	id := v.v.t
+	if v.v.id != 0 {
+		id = v.v.id
+	}
+	return v.d.typeInfo(id, map[typeID]*TypeInfo{})
+}
+
+// typeInfo returns the TypeInfo of id, reusing those in infos so that
+// recursive types refer back to themselves
+func (d *decoder) typeInfo(id typeID, infos map[typeID]*TypeInfo) *TypeInfo {
+	if ti, ok := infos[id]; ok {
+		return ti
+	}
+	ti := &TypeInfo{
+		ID:   int(id),
+		Name: d.idToType(int(id)),
+		Kind: d.typeKind(id),
+	}
+	infos[id] = ti
+	w, def, ok := d.wireDef(id)
+	if !ok {
+		return ti
+	}
+	switch w {
+	case wireArray:
+		ti.Len = defLen(def)
+		ti.Elem = d.typeInfo(defElem(w, def), infos)
+	case wireSlice:
+		ti.Elem = d.typeInfo(defElem(w, def), infos)
+	case wireMap:
+		ti.Key = d.typeInfo(defKey(def), infos)
+		ti.Elem = d.typeInfo(defElem(w, def), infos)
+	case wireStruct:
+		for _, f := range defFields(def) {
+			ti.Fields = append(ti.Fields, FieldInfo{
+				Name: f.name,
+				Type: d.typeInfo(f.id, infos),
+			})
+		}
+	}
+	return ti
+}
@@ -0,0 +1,89 @@
+package goblin
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTypes(t *testing.T) {
+	type leaf struct {
+		Colour uint
+	}
+	type tree struct {
+		Name   string
+		Leaves map[string][]leaf
+		Sizes  [3]int
+		Kids   []tree
+	}
+	d := scanOne(t, tree{Name: "root"})
+
+	var root *TypeInfo
+	for _, ti := range d.Types() {
+		if ti.Name == "tree" {
+			root = ti
+		}
+	}
+	if root == nil {
+		t.Fatal("no tree type")
+	}
+	if root.Kind != Struct || len(root.Fields) != 4 {
+		t.Fatalf("bad tree %+v", root)
+	}
+	exp := []struct {
+		name, typ string
+		kind      Kind
+	}{
+		{"Name", "string", String},
+		{"Leaves", "map[string][]leaf", Map},
+		{"Sizes", "[3]int64", Array},
+		{"Kids", "[]tree", Slice},
+	}
+	for i, e := range exp {
+		f := root.Fields[i]
+		if f.Name != e.name || f.Type.String() != e.typ || f.Type.Kind != e.kind {
+			t.Errorf("field %d got %s %s %s", i, f.Name, f.Type, f.Type.Kind)
+		}
+	}
+
+	leaves := root.Fields[1].Type
+	if leaves.Key.Kind != String || leaves.Elem.Elem.Fields[0].Type.Kind != Uint {
+		t.Errorf("bad map type %+v", leaves)
+	}
+	if root.Fields[2].Type.Len != 3 {
+		t.Error("bad array len", root.Fields[2].Type.Len)
+	}
+	// recursive types lead back to themselves
+	if root.Fields[3].Type.Elem != root {
+		t.Error("recursive type not shared")
+	}
+
+	if ti := d.Value().Type(); ti.Name != "tree" || ti.ID != root.ID {
+		t.Errorf("bad value type %+v", ti)
+	}
+	if f, _ := d.Value().Field("Name"); f.Type().Name != "string" {
+		t.Error("bad field type", f.Type())
+	}
+}
+
+func TestTypeNames(t *testing.T) {
+	type named struct {
+		Anon struct{ X int }
+		Nums []uint
+		Pair [2]string
+	}
+	ti := scanOne(t, named{}).Value().Type()
+	if ti.Name != "named" {
+		t.Fatalf("bad type %+v", ti)
+	}
+	// unnamed structs are named by their decoded id
+	anon := ti.Fields[0].Type
+	if anon.Name != fmt.Sprintf("Type%d", anon.ID) {
+		t.Errorf("got %s for id %d", anon.Name, anon.ID)
+	}
+	// unnamed slices and arrays are named by their Go type
+	for _, f := range ti.Fields[1:] {
+		if f.Type.Name != f.Type.String() {
+			t.Errorf("%s got %s for %s", f.Name, f.Type.Name, f.Type)
+		}
+	}
+}
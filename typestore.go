@@ -2,7 +2,9 @@ package goblin
 
 import (
 	"fmt"
+	"go/types"
 	"io"
+	"unicode"
 )
 
 const (
@@ -18,43 +20,34 @@ const (
 	minUserType typeID = 30
 )
 
-// v has to be a val representing a wiretype
-func (d *decoder) toType(w io.Writer, v *val) {
-	// must be a wireType
-	if v.t != tStruct {
-		return
+// toType writes the declaration of the user type id, returning false if it
+// has none, as slices, arrays and maps that gob sent no name for are literals
+func (d *decoder) toType(w io.Writer, id typeID) bool {
+	wt, def, ok := d.wireDef(id)
+	if !ok {
+		return false
 	}
-	// get the name
-	name := wireTypeName(v)
-	// what type of wireType
-	for i, f := range v.st {
-		if f.nonZero {
-			switch i {
-			case 0: // Array field
-				tName := fmt.Sprintf("Type%d", f.v.st[0].v.st[1].v.nu)
-				kt := d.idToType(int(f.v.st[1].v.ToInt()))
-				l := f.v.st[2].v.ToInt()
-				fmt.Fprintf(w, "type %s [%d]%s\t//%s\n", tName, l, kt, name)
-			case 1: // slice field - name is the original type
-				tName := fmt.Sprintf("Type%d", f.v.st[0].v.st[1].v.nu)
-				kt := d.idToType(int(f.v.st[1].v.ToInt()))
-				fmt.Fprintf(w, "type %s []%s\t//%s\n", tName, kt, name)
-			case 2: // struct field
-				fmt.Fprintf(w, "type %s struct {\n", name)
-				for _, fn := range f.v.st[1].v.sl.els {
-					ty := d.idToType(int(fn.st[1].v.ToInt()))
-					fmt.Fprintf(w, "  %s %s\n", string(fn.st[0].v.da), ty)
-				}
-				fmt.Fprintln(w, "}")
-			case 3: // map field
-				kt := d.idToType(int(f.v.st[1].v.ToInt()))
-				kv := d.idToType(int(f.v.st[2].v.ToInt()))
-				fmt.Fprintf(w, "type %s map[%s]%s\n", name, kt, kv)
-			}
+	name := d.idToType(int(id))
+	if wt != wireStruct {
+		if !isIdent(defName(def)) {
+			return false
 		}
+		fmt.Fprintf(w, "type %s %s\n", name, d.typeLit(wt, def))
+		return true
 	}
+	fmt.Fprintf(w, "type %s struct {\n", name)
+	for _, f := range defFields(def) {
+		ty := d.idToType(int(f.id))
+		if h := d.hints.field(name, f.name, d.wireKind(f.id)); h != nil {
+			ty = types.ExprString(h)
+		}
+		fmt.Fprintf(w, "  %s %s\n", f.name, ty)
+	}
+	fmt.Fprintln(w, "}")
+	return true
 }
 
+// wireTypeName returns the name gob sent for the wireType v
 func wireTypeName(v *val) string {
 	// must be a wiretype struct
 	if v.t != tStruct {
@@ -63,26 +56,145 @@ func wireTypeName(v *val) string {
 	// what type of wireType
 	for _, f := range v.st {
 		if f.nonZero {
-			name := string(f.v.st[0].v.st[0].v.da)
-			if name == "" {
-				name = fmt.Sprintf("Type%d", f.v.st[0].v.st[1].v.nu)
-			}
-			return name
+			return defName(f.v)
 		}
 	}
 	return ""
 }
 
+// defName returns the name in the commonType of a type definition. Gob sends
+// the names of named types, and for the rest their Go type, or nothing.
+func defName(def val) string {
+	return string(def.st[0].v.st[0].v.da)
+}
+
+// isIdent returns true if name is a Go identifier, so can name a type
+func isIdent(name string) bool {
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// the fields of the wireType, in order, only one of which is set
+const (
+	wireArray = iota
+	wireSlice
+	wireStruct
+	wireMap
+)
+
+// wireDef returns which of the wireType fields is set for the user type id,
+// and the arrayType, sliceType, structType or mapType it holds
+func (d *decoder) wireDef(id typeID) (int, val, bool) {
+	if id < minUserType {
+		return -1, val{}, false
+	}
+	ty, ok := d.types[id]
+	if !ok {
+		return -1, val{}, false
+	}
+	for i, f := range ty.st {
+		if f.nonZero {
+			return i, f.v, true
+		}
+	}
+	return -1, val{}, false
+}
+
+// wireKind returns the primitive type id that values of type id are
+// decoded as, tSlice for arrays and slices, tStruct or tMap
+func (d *decoder) wireKind(id typeID) typeID {
+	if id < minUserType {
+		return id
+	}
+	w, _, ok := d.wireDef(id)
+	if !ok {
+		return 0
+	}
+	switch w {
+	case wireArray, wireSlice:
+		return tSlice
+	case wireStruct:
+		return tStruct
+	}
+	return tMap
+}
+
+// typeKind returns the Kind of values of type id
+func (d *decoder) typeKind(id typeID) Kind {
+	w, _, _ := d.wireDef(id)
+	return kindOf(d.wireKind(id), w == wireArray)
+}
+
+// typeField is a field of a struct type
+type typeField struct {
+	name string
+	id   typeID
+}
+
+// defFields returns the fields of a structType definition
+func defFields(def val) []typeField {
+	fs := make([]typeField, len(def.st[1].v.sl.els))
+	for i, f := range def.st[1].v.sl.els {
+		fs[i] = typeField{
+			name: string(f.st[0].v.da),
+			id:   typeID(f.st[1].v.ToInt()),
+		}
+	}
+	return fs
+}
+
+// defElem returns the elem type of an arrayType, sliceType or mapType definition
+func defElem(w int, def val) typeID {
+	if w == wireMap {
+		return typeID(def.st[2].v.ToInt())
+	}
+	return typeID(def.st[1].v.ToInt())
+}
+
+// defKey returns the key type of a mapType definition
+func defKey(def val) typeID {
+	return typeID(def.st[1].v.ToInt())
+}
+
+// defLen returns the length of an arrayType definition
+func defLen(def val) int {
+	return int(def.st[2].v.ToInt())
+}
+
+// idToType returns the Go type of values of type id, as WriteTypes declares
+// them. Types that gob sent no usable name for are structs named Type<ID> or
+// slice, array and map literals built from their element types.
 func (d *decoder) idToType(id int) string {
 	t := typeLookup[id]
 	if t != "" {
 		return t
 	}
-	ty, ok := d.types[typeID(id)]
+	w, def, ok := d.wireDef(typeID(id))
 	if !ok {
 		return ""
 	}
-	return wireTypeName(&ty)
+	if name := defName(def); isIdent(name) {
+		return name
+	}
+	if w == wireStruct {
+		return fmt.Sprintf("Type%d", id)
+	}
+	return d.typeLit(w, def)
+}
+
+// typeLit returns the Go type literal of an arrayType, sliceType or mapType definition
+func (d *decoder) typeLit(w int, def val) string {
+	switch w {
+	case wireArray:
+		return fmt.Sprintf("[%d]%s", defLen(def), d.idToType(int(defElem(w, def))))
+	case wireSlice:
+		return "[]" + d.idToType(int(defElem(w, def)))
+	}
+	return fmt.Sprintf("map[%s]%s", d.idToType(int(defKey(def))), d.idToType(int(defElem(w, def))))
 }
 
 // setupTypes sets up the types index of types.
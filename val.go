@@ -3,6 +3,8 @@ package goblin
 import (
 	"math"
 	"math/bits"
+	"sort"
+	"strconv"
 )
 
 const (
@@ -20,6 +22,29 @@ const (
 	tStruct typeID = 11
 )
 
+// AbsentMode selects how struct fields that were not present in the gob data are rendered
+type AbsentMode int
+
+const (
+	// AbsentZero renders absent fields as their Go zero value, as encoding/gob would produce.
+	// Pointers are nil, which gob does not send so is known for recursive
+	// fields, that can only be pointers, and fields declared so in TypeHints.
+	AbsentZero AbsentMode = iota
+	// AbsentOmit leaves absent fields out of the rendered struct
+	AbsentOmit
+	// AbsentNull renders absent fields as nil, which is null in json
+	AbsentNull
+)
+
+// objOpts controls how vals are converted by obj
+type objOpts struct {
+	absent  AbsentMode
+	ordered bool // structs keep their field order, for json output
+
+	bigInts   bool          // 64 bit integers beyond 2^53 are rendered as strings
+	nonFinite NonFiniteMode // how NaN and infinite floats are rendered
+}
+
 var typeLookup = map[int]string{
 	1:  "bool",
 	2:  "int64",
@@ -37,91 +62,108 @@ var typeLookup = map[int]string{
 type typeID int
 
 type mapv struct {
-	kt  typeID // key type id
-	vt  typeID // value type id
-	els map[string]val
-}
-
-func (m *mapv) copy(om mapv) {
-	m.kt = om.kt
-	m.vt = om.vt
-	for k, v := range om.els {
-		nv := val{}
-		nv.copy(v)
-		m.els[k] = nv
-	}
+	kt   typeID         // key type id
+	vt   typeID         // value type id
+	keys []val          // the typed keys in the order they were decoded
+	els  map[string]val // the values by the string representation of their key, see key
+}
+
+// sortedKeys returns the typed keys sorted by their string representation
+func (m mapv) sortedKeys() []val {
+	ks := make([]val, len(m.keys))
+	copy(ks, m.keys)
+	sort.Slice(ks, func(i, j int) bool {
+		return ks[i].key() < ks[j].key()
+	})
+	return ks
 }
 
-func (m mapv) obj() interface{} {
+// numericKeys returns the typed keys in order, numbers by their value with
+// NaN first, and the rest by their string representation
+func (m mapv) numericKeys() []val {
+	ks := make([]val, len(m.keys))
+	copy(ks, m.keys)
+	sort.Slice(ks, func(i, j int) bool {
+		switch ks[i].t {
+		case tInt:
+			return ks[i].ToInt() < ks[j].ToInt()
+		case tUint:
+			return ks[i].ToUint() < ks[j].ToUint()
+		case tFloat:
+			a, b := ks[i].ToFloat(), ks[j].ToFloat()
+			return a < b || math.IsNaN(a) && !math.IsNaN(b)
+		}
+		return ks[i].key() < ks[j].key()
+	})
+	return ks
+}
+
+func (m mapv) obj(o objOpts) interface{} {
 	ma := map[string]interface{}{}
 	for k, v := range m.els {
-		ma[k] = v.obj()
+		ma[k] = v.obj(o)
 	}
 	return ma
 }
 
 // the slice value
 type slice struct {
-	t   typeID
-	els []val
+	t     typeID
+	array bool // arrays are slices with their fixed length of elements pre made
+	els   []val
 }
 
-func (s slice) obj() interface{} {
+func (s slice) obj(o objOpts) interface{} {
 	var ar []interface{}
 	for _, v := range s.els {
-		ar = append(ar, v.obj())
+		ar = append(ar, v.obj(o))
 	}
 	return ar
 }
 
-func (s *slice) copy(os slice) {
-	s.t = os.t
-	s.els = make([]val, len(os.els))
-	for i, v := range os.els {
-		nv := val{}
-		nv.copy(v)
-		s.els[i] = nv
-	}
-}
-
 // the field value
 type field struct {
-	nonZero bool
+	nonZero bool // the field was present in the gob data
+	nilZero bool // the zero value of the field is nil, as it is a pointer
 	name    string
 	v       val
 }
 
-// tructs are slices of fields
-type structv []field
-
-func (s structv) obj() interface{} {
-	ma := map[string]interface{}{}
-	for _, v := range s {
-		ma[v.name] = v.v.obj()
+// absentAs returns how the field is rendered in mode m if it is absent, which
+// for a pointer in AbsentZero mode is as nil
+func (f *field) absentAs(m AbsentMode) AbsentMode {
+	if m == AbsentZero && f.nilZero {
+		return AbsentNull
 	}
-	return ma
+	return m
 }
 
-func (s *structv) copy(os structv) {
-	fls := make([]field, len(os))
-	for i, f := range os {
-		nf := field{}
-		nf.copy(f)
-		fls[i] = nf
-	}
-	*s = structv(fls)
-}
+// tructs are slices of fields
+type structv []field
 
-func (f *field) copy(of field) {
-	f.nonZero = of.nonZero
-	f.name = of.name
-	f.v = val{}
-	f.v.copy(of.v)
+func (s structv) obj(o objOpts) interface{} {
+	fs := make(orderedObj, 0, len(s))
+	for i := range s {
+		v := &s[i]
+		if v.nonZero {
+			fs = append(fs, objField{name: v.name, v: v.v.obj(o)})
+			continue
+		}
+		switch v.absentAs(o.absent) {
+		case AbsentOmit:
+		case AbsentNull:
+			fs = append(fs, objField{name: v.name})
+		default:
+			fs = append(fs, objField{name: v.name, v: v.v.zero(o)})
+		}
+	}
+	return fs.obj(o)
 }
 
 // val represents values of any of the builtin type
 type val struct {
 	t  typeID  // what primitive type id
+	id typeID  // the user type id a slice, map or struct was made from
 	da []byte  // for strings and []byte
 	nu uint64  // for all int, uint, float
 	sl slice   // for slice type
@@ -155,84 +197,83 @@ func (v val) ToBool() bool {
 	return v.da[0] == 1 // should always be a 1
 }
 
-func (v val) obj() interface{} {
+func (v val) obj(o objOpts) interface{} {
 	switch v.t {
 	case tBool:
 		return v.ToBool()
 	case tInt:
-		return v.ToInt()
+		i := v.ToInt()
+		if o.bigInts && (i > maxSafeInt || i < -maxSafeInt) {
+			return strconv.FormatInt(i, 10)
+		}
+		return i
 	case tUint:
-		return v.ToUint()
+		u := v.ToUint()
+		if o.bigInts && u > maxSafeInt {
+			return strconv.FormatUint(u, 10)
+		}
+		return u
 	case tBytes:
 		return v.da
 	case tFloat:
-		return v.ToFloat()
+		return o.float(v.ToFloat())
 	case tString:
 		return string(v.da)
 	case tSlice:
-		return v.sl.obj()
+		return v.sl.obj(o)
 	case tStruct:
-		return v.st.obj()
+		return v.st.obj(o)
 	case tMap:
-		return v.ma.obj()
+		return v.ma.obj(o)
 	}
 	return nil
 }
 
-func (v *val) copy(t val) {
-	v.t = t.t
-	v.da = nil
-	v.ma.copy(t.ma)
-	v.sl.copy(t.sl)
-	v.st.copy(t.st)
+// key returns the standard string representation of a map key, as fmt would
+// print it for the primitive types. Structs and arrays, which can be keys too,
+// are rendered as compact json so they read the same as the values do.
+func (v val) key() string {
+	switch v.t {
+	case tString:
+		return string(v.da)
+	case tInt:
+		return strconv.FormatInt(v.ToInt(), 10)
+	case tUint:
+		return strconv.FormatUint(v.ToUint(), 10)
+	case tFloat:
+		return strconv.FormatFloat(v.ToFloat(), 'g', -1, 64)
+	case tBool:
+		return strconv.FormatBool(v.ToBool())
+	}
+	return compactJSON(v.obj(objOpts{ordered: true, nonFinite: NonFiniteString}))
 }
 
-// v must be a representation of a wire type
-func (d *decoder) fromWireType(v val) val {
-	// the data val
-	dv := val{}
-
-	var name string
-	// what type are we constructing
-	for _, t := range v.st {
-		if t.nonZero {
-			name = t.name
-			v = t.v
-			break
+// zero returns the Go zero value of the type that v is a template of,
+// matching what encoding/gob leaves in a field it received no data for
+func (v val) zero(o objOpts) interface{} {
+	switch v.t {
+	case tBytes:
+		return []byte(nil)
+	case tSlice:
+		if !v.sl.array {
+			return []interface{}(nil)
 		}
-	}
-
-	switch name {
-	case "mapT":
-		dv.t = tMap
-		dv.ma.kt = typeID(v.st[1].v.ToInt())
-		dv.ma.vt = typeID(v.st[2].v.ToInt())
-	case "structT":
-		dv.t = tStruct
-		for _, f := range v.st[1].v.sl.els { // sl is a slice of field types
-			fld := field{
-				name: string(f.st[0].v.da), // first field of the field definition is name
-				v:    d.makeVal(typeID(f.st[1].v.ToInt())),
-			}
-			if fld.v.t == tBool {
-				fld.nonZero = true // missing field data == a false value
+		ar := make([]interface{}, len(v.sl.els))
+		for i, e := range v.sl.els {
+			ar[i] = e.zero(o)
+		}
+		return ar
+	case tMap:
+		return map[string]interface{}(nil)
+	case tStruct:
+		fs := make(orderedObj, len(v.st))
+		for i, f := range v.st {
+			fs[i] = objField{name: f.name}
+			if !f.nilZero {
+				fs[i].v = f.v.zero(o)
 			}
-			dv.st = append(dv.st, fld)
 		}
-	case "sliceT", "arrayT":
-		dv.t = tSlice
-		dv.sl.t = typeID(v.st[1].v.ToInt()) // second field is the 'elem' field
-	}
-
-	return dv
-}
-
-func (d *decoder) makeVal(t typeID) val {
-	// is it a wiretype definition in the table
-	if t >= minUserType {
-		return d.fromWireType(d.types[t])
-	}
-	return val{
-		t: t,
+		return fs.obj(o)
 	}
+	return v.obj(o)
 }
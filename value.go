@@ -0,0 +1,325 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// Kind is the kind of a decoded Value
+type Kind int
+
+const (
+	// Invalid is the kind of the zero Value
+	Invalid Kind = iota
+	// Bool is a bool
+	Bool
+	// Int is any signed integer, which gob sends as an int64
+	Int
+	// Uint is any unsigned integer, which gob sends as a uint64
+	Uint
+	// Float is a float32 or float64, which gob sends as a float64
+	Float
+	// Bytes is a []byte
+	Bytes
+	// String is a string
+	String
+	// Slice is a slice of any other type than byte
+	Slice
+	// Array is a fixed length array
+	Array
+	// Map is a map, with keys of any kind that gob can send
+	Map
+	// Struct is a struct of the exported fields gob sent
+	Struct
+)
+
+var kindNames = []string{
+	Invalid: "invalid",
+	Bool:    "bool",
+	Int:     "int",
+	Uint:    "uint",
+	Float:   "float",
+	Bytes:   "bytes",
+	String:  "string",
+	Slice:   "slice",
+	Array:   "array",
+	Map:     "map",
+	Struct:  "struct",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[Invalid]
+	}
+	return kindNames[k]
+}
+
+// Value gives read only access to a decoded gob value and its children.
+// The zero Value is invalid.
+type Value struct {
+	v    *val
+	d    *decoder // the decoder the value came from, for its options and types
+	hint ast.Expr // the type declared in any type hints
+}
+
+// Value returns the result of the last Scan, it is invalid if there is none
+func (d *decoder) Value() Value {
+	if d.lastVal == nil {
+		return Value{}
+	}
+	return Value{v: d.lastVal, d: d}
+}
+
+// IsValid reports whether v represents a value
+func (v Value) IsValid() bool {
+	return v.v != nil
+}
+
+// Kind returns the kind of the value
+func (v Value) Kind() Kind {
+	if v.v == nil {
+		return Invalid
+	}
+	return kindOf(v.v.t, v.v.sl.array)
+}
+
+// kindOf returns the Kind of vals of primitive type t
+func kindOf(t typeID, array bool) Kind {
+	switch t {
+	case tBool:
+		return Bool
+	case tInt:
+		return Int
+	case tUint:
+		return Uint
+	case tFloat:
+		return Float
+	case tBytes:
+		return Bytes
+	case tString:
+		return String
+	case tSlice:
+		if array {
+			return Array
+		}
+		return Slice
+	case tMap:
+		return Map
+	case tStruct:
+		return Struct
+	}
+	return Invalid
+}
+
+// ToBool returns the bool value, false for any non bool
+func (v Value) ToBool() bool {
+	if v.Kind() != Bool {
+		return false
+	}
+	return v.v.ToBool()
+}
+
+// ToInt returns the signed integer value, zero for any non int
+func (v Value) ToInt() int64 {
+	if v.Kind() != Int {
+		return 0
+	}
+	return v.v.ToInt()
+}
+
+// ToUint returns the unsigned integer value, zero for any non uint
+func (v Value) ToUint() uint64 {
+	if v.Kind() != Uint {
+		return 0
+	}
+	return v.v.ToUint()
+}
+
+// ToFloat returns the float value, zero for any non float
+func (v Value) ToFloat() float64 {
+	if v.Kind() != Float {
+		return 0
+	}
+	return v.v.ToFloat()
+}
+
+// ToString returns the string value, empty for any non string
+func (v Value) ToString() string {
+	if v.Kind() != String {
+		return ""
+	}
+	return string(v.v.da)
+}
+
+// ToBytes returns the []byte value, nil for any non []byte
+func (v Value) ToBytes() []byte {
+	if v.Kind() != Bytes {
+		return nil
+	}
+	return v.v.da
+}
+
+// Len returns the number of elements in a slice, array or map, or the number
+// of fields in a struct
+func (v Value) Len() int {
+	switch v.Kind() {
+	case Slice, Array:
+		return len(v.v.sl.els)
+	case Map:
+		return len(v.v.ma.els)
+	case Struct:
+		return len(v.v.st)
+	}
+	return 0
+}
+
+// Index returns the i'th element of a slice or array, it is invalid if out of range
+func (v Value) Index(i int) Value {
+	k := v.Kind()
+	if (k != Slice && k != Array) || i < 0 || i >= len(v.v.sl.els) {
+		return Value{}
+	}
+	e := &v.v.sl.els[i]
+	return Value{v: e, d: v.d, hint: v.d.hintElem(v.hint, e.t)}
+}
+
+// Keys returns the sorted string representation of the keys of a map
+func (v Value) Keys() []string {
+	if v.Kind() != Map {
+		return nil
+	}
+	ks := make([]string, 0, len(v.v.ma.els))
+	for k := range v.v.ma.els {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// MapKeys returns the keys of a map as values, sorted as Keys are, by their
+// string representation, which gives access to the fields of struct keys and
+// the elements of array keys
+func (v Value) MapKeys() []Value {
+	if v.Kind() != Map {
+		return nil
+	}
+	ks := v.v.ma.sortedKeys()
+	vs := make([]Value, len(ks))
+	for i := range ks {
+		vs[i] = Value{v: &ks[i], d: v.d}
+	}
+	return vs
+}
+
+// MapIndex returns the map element with the given key, struct and array keys
+// are given as the compact json that Keys returns for them
+func (v Value) MapIndex(key string) (Value, bool) {
+	if v.Kind() != Map {
+		return Value{}, false
+	}
+	e, ok := v.v.ma.els[key]
+	if !ok {
+		return Value{}, false
+	}
+	return Value{v: &e, d: v.d, hint: v.d.hintElem(v.hint, e.t)}, true
+}
+
+// Fields returns the struct field names in their declared order
+func (v Value) Fields() []string {
+	if v.Kind() != Struct {
+		return nil
+	}
+	names := make([]string, len(v.v.st))
+	for i, f := range v.v.st {
+		names[i] = f.name
+	}
+	return names
+}
+
+// Field returns the named struct field. A field that was absent from the gob
+// data is returned holding its zero value, use Present to tell the difference.
+func (v Value) Field(name string) (Value, bool) {
+	i := v.fieldIndex(name)
+	if i < 0 {
+		return Value{}, false
+	}
+	f := &v.v.st[i].v
+	var h ast.Expr
+	if v.d != nil {
+		h = v.d.hints.field(v.structName(), name, f.t)
+	}
+	return Value{v: f, d: v.d, hint: h}, true
+}
+
+// Present reports whether the named struct field was present in the gob data.
+// Gob does not send zero valued fields so absent fields hold the zero value.
+func (v Value) Present(name string) bool {
+	i := v.fieldIndex(name)
+	if i < 0 {
+		return false
+	}
+	return v.v.st[i].nonZero
+}
+
+func (v Value) fieldIndex(name string) int {
+	if v.Kind() != Struct {
+		return -1
+	}
+	for i, f := range v.v.st {
+		if f.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// TypeName returns the Go type of the value. Gob only sends 64 bit numbers so
+// ints, uints and floats are reported as such unless TypeHints declared their
+// original type.
+func (v Value) TypeName() string {
+	switch {
+	case v.v == nil:
+		return ""
+	case v.hint != nil:
+		return types.ExprString(v.hint)
+	case v.v.id >= minUserType && v.d != nil:
+		return v.d.idToType(int(v.v.id))
+	}
+	return typeLookup[int(v.v.t)]
+}
+
+// structName is the wire name of a struct value
+func (v Value) structName() string {
+	if v.d == nil {
+		return ""
+	}
+	ty, ok := v.d.types[v.v.id]
+	if !ok {
+		return ""
+	}
+	return wireTypeName(&ty)
+}
+
+// hintElem returns the hinted type of the elements of h, if they are wire kind t
+func (d *decoder) hintElem(h ast.Expr, t typeID) ast.Expr {
+	if d == nil || h == nil {
+		return nil
+	}
+	return d.hints.elem(h, t)
+}
+
+// Obj returns the value as a json compatible object, as the decoder Obj does
+func (v Value) Obj() interface{} {
+	if v.v == nil {
+		return nil
+	}
+	return v.v.obj(v.opts())
+}
+
+func (v Value) opts() objOpts {
+	if v.d == nil {
+		return objOpts{}
+	}
+	return v.d.opts
+}
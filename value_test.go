@@ -0,0 +1,133 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+	"testing"
+)
+
+type absentInner struct {
+	Tags []string
+}
+
+type absentThing struct {
+	Name  string
+	Sane  bool
+	Nums  []int
+	Sizes [2]int
+	Inner *absentInner
+	Index map[string]int
+}
+
+func scanOne(t *testing.T, v interface{}) *decoder {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(v)
+	if err != nil {
+		t.Fatal("shame", err)
+	}
+	d := New(buf)
+	if !d.Scan() {
+		t.Fatal("got a decode error:", d.Err())
+	}
+	return d
+}
+
+func TestAbsentModes(t *testing.T) {
+	cases := []struct {
+		mode AbsentMode
+		exp  string
+	}{
+		{
+			mode: AbsentZero,
+			exp:  `{"Index":null,"Inner":{"Tags":null},"Name":"jo","Nums":null,"Sane":false,"Sizes":[0,0]}`,
+		},
+		{
+			mode: AbsentOmit,
+			exp:  `{"Name":"jo","Sizes":[0,0]}`,
+		},
+		{
+			mode: AbsentNull,
+			exp:  `{"Index":null,"Inner":null,"Name":"jo","Nums":null,"Sane":null,"Sizes":[0,0]}`,
+		},
+	}
+	for _, c := range cases {
+		d := scanOne(t, absentThing{Name: "jo"})
+		d.SetAbsent(c.mode)
+		b, err := d.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := compact(t, b); got != c.exp {
+			t.Errorf("mode %d got %s", c.mode, got)
+		}
+	}
+}
+
+func TestValuePresence(t *testing.T) {
+	d := scanOne(t, absentThing{
+		Name:  "jo",
+		Nums:  []int{3, -4},
+		Inner: &absentInner{},
+		Index: map[string]int{"a": 1},
+	})
+	v := d.Value()
+	if v.Kind() != Struct {
+		t.Fatal("expected a struct got", v.Kind())
+	}
+	if len(v.Fields()) != 6 {
+		t.Error("wrong field count", v.Fields())
+	}
+	for name, exp := range map[string]bool{
+		"Name": true, "Sane": false, "Nums": true, "Sizes": true, "Inner": true, "Index": true, "Nope": false,
+	} {
+		if v.Present(name) != exp {
+			t.Errorf("%s present should be %t", name, exp)
+		}
+	}
+
+	sane, ok := v.Field("Sane")
+	if !ok || sane.Kind() != Bool || sane.ToBool() {
+		t.Error("absent bool should be a false bool")
+	}
+	nums, _ := v.Field("Nums")
+	if nums.Kind() != Slice || nums.Len() != 2 || nums.Index(1).ToInt() != -4 {
+		t.Error("bad slice value")
+	}
+	if nums.Index(2).IsValid() {
+		t.Error("out of range index should be invalid")
+	}
+	sizes, _ := v.Field("Sizes")
+	if sizes.Kind() != Array || sizes.Len() != 2 {
+		t.Error("bad array value")
+	}
+	idx, _ := v.Field("Index")
+	one, ok := idx.MapIndex("a")
+	if !ok || one.ToInt() != 1 {
+		t.Error("bad map value")
+	}
+	inner, _ := v.Field("Inner")
+	if inner.Present("Tags") {
+		t.Error("inner tags should be absent")
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	v := scanOne(t, map[int]string{2: "b", 10: "c", -1: "a"}).Value()
+	var got []int64
+	for _, k := range v.MapKeys() {
+		got = append(got, k.ToInt())
+	}
+	// in the order of Keys, which sorts their strings
+	exp := v.Keys()
+	if len(got) != 3 || len(exp) != 3 {
+		t.Fatal("got keys", got, exp)
+	}
+	for i, k := range []int64{-1, 10, 2} {
+		if got[i] != k || exp[i] != strconv.FormatInt(k, 10) {
+			t.Errorf("got keys %v and %q", got, exp)
+			break
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package goblin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// YAML returns the result of the last Scan as a block style YAML document,
+// with struct fields in their declared order and map keys in their typed
+// form, so int keys stay ints. Struct and array keys are flow style complex keys.
+func (d *decoder) YAML() ([]byte, error) {
+	if d.lastVal == nil {
+		return nil, errors.New("can not return yaml representation of none existant object")
+	}
+	y := yamlWriter{o: d.opts}
+	if y.block(d.lastVal) {
+		y.blockNode(d.lastVal, 0, false)
+	} else {
+		y.buf.WriteString(y.scalar(d.lastVal))
+		y.buf.WriteByte('\n')
+	}
+	return y.buf.Bytes(), nil
+}
+
+type yamlWriter struct {
+	buf bytes.Buffer
+	o   objOpts
+}
+
+// block returns true if v is written as an indented block, false if it
+// fits on the line of its key or sequence dash
+func (y *yamlWriter) block(v *val) bool {
+	switch v.t {
+	case tSlice:
+		return len(v.sl.els) > 0
+	case tMap:
+		return len(v.ma.els) > 0
+	case tStruct:
+		if y.o.absent != AbsentOmit {
+			return len(v.st) > 0
+		}
+		for _, f := range v.st {
+			if f.nonZero {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (y *yamlWriter) indent(n int) {
+	for i := 0; i < n; i++ {
+		y.buf.WriteByte(' ')
+	}
+}
+
+// blockNode writes the slice, map or struct v at the indent, apart from the
+// first line if inline, which follows a sequence dash
+func (y *yamlWriter) blockNode(v *val, indent int, inline bool) {
+	first := true
+	line := func() {
+		if !first || !inline {
+			y.indent(indent)
+		}
+		first = false
+	}
+	switch v.t {
+	case tSlice:
+		for i := range v.sl.els {
+			line()
+			y.buf.WriteString("- ")
+			y.item(&v.sl.els[i], indent+2, true)
+		}
+	case tMap:
+		for _, k := range v.ma.numericKeys() {
+			line()
+			e := v.ma.els[k.key()]
+			if k.t == tStruct || k.t == tSlice {
+				// a complex key
+				y.buf.WriteString("? ")
+				y.buf.WriteString(compactJSON(k.obj(objOpts{ordered: true, nonFinite: NonFiniteString})))
+				y.buf.WriteByte('\n')
+				y.indent(indent)
+			} else {
+				y.buf.WriteString(y.scalar(&k))
+			}
+			y.buf.WriteByte(':')
+			y.item(&e, indent+2, false)
+		}
+	case tStruct:
+		for i := range v.st {
+			f := &v.st[i]
+			if !f.nonZero && y.o.absent == AbsentOmit {
+				continue
+			}
+			line()
+			y.buf.WriteString(yamlString(f.name))
+			y.buf.WriteByte(':')
+			if !f.nonZero && f.absentAs(y.o.absent) == AbsentNull {
+				y.buf.WriteString(" null\n")
+				continue
+			}
+			y.item(&f.v, indent+2, false)
+		}
+	}
+}
+
+// item writes v as a map value or sequence entry, dashed when it follows a
+// sequence dash, indenting any block it needs
+func (y *yamlWriter) item(v *val, indent int, dashed bool) {
+	if !y.block(v) {
+		if !dashed {
+			y.buf.WriteByte(' ')
+		}
+		y.buf.WriteString(y.scalar(v))
+		y.buf.WriteByte('\n')
+		return
+	}
+	if dashed {
+		y.blockNode(v, indent, true)
+		return
+	}
+	y.buf.WriteByte('\n')
+	y.blockNode(v, indent, false)
+}
+
+// scalar returns the flow form of v, the empty form for slices, maps and structs
+func (y *yamlWriter) scalar(v *val) string {
+	switch v.t {
+	case tBool:
+		return strconv.FormatBool(v.ToBool())
+	case tInt:
+		return strconv.FormatInt(v.ToInt(), 10)
+	case tUint:
+		return strconv.FormatUint(v.ToUint(), 10)
+	case tFloat:
+		f := v.ToFloat()
+		switch {
+		case math.IsNaN(f):
+			return ".nan"
+		case math.IsInf(f, 1):
+			return ".inf"
+		case math.IsInf(f, -1):
+			return "-.inf"
+		}
+		return floatText(f)
+	case tBytes:
+		return "!!binary " + base64.StdEncoding.EncodeToString(v.da)
+	case tString:
+		return yamlString(string(v.da))
+	case tSlice:
+		return "[]"
+	case tMap, tStruct:
+		return "{}"
+	}
+	return "null"
+}
+
+// yamlPlain are the words that would not be read back as strings if unquoted
+var yamlPlain = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+// yamlString returns s plain if it will be read back as the same string,
+// otherwise double quoted
+func yamlString(s string) string {
+	// numbers, and dates too, start with a digit
+	if s == "" || yamlPlain[strings.ToLower(s)] || s[0] <= '9' || s[len(s)-1] == ' ' {
+		return quote(s)
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ' ':
+		case i > 0 && (r == '-' || r == '.' || r == '/'):
+		case r > utf8.RuneSelf && r != utf8.RuneError && r != '\u2028' && r != '\u2029' && r != '\ufeff':
+		default:
+			return quote(s)
+		}
+	}
+	return s
+}
+
+// quote double quotes s with the escapes that YAML and TOML have in common
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f || r == '\u2028' || r == '\u2029' || r == '\ufeff' {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// floatText formats the finite f so that it is read back as a float, not an int
+func floatText(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
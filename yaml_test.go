@@ -0,0 +1,94 @@
+package goblin
+
+import (
+	"math"
+	"testing"
+)
+
+type cfgServer struct {
+	Host  string
+	Port  int
+	Debug bool
+}
+
+type cfg struct {
+	Name    string
+	Version uint
+	Ratio   float64
+	Servers []cfgServer
+	Limits  map[int]string
+	Labels  map[string]string
+	Matrix  [][]int
+	Main    cfgServer
+	Spots   map[point]string
+	Raw     []byte
+	Missing string
+	Inf     float64
+}
+
+var cfgValue = cfg{
+	Name:    "true",
+	Version: 3,
+	Ratio:   2,
+	Servers: []cfgServer{{Host: "a.example", Port: 80}, {Host: "b: c", Port: 81, Debug: true}},
+	Limits:  map[int]string{10: "ten", 2: "two"},
+	Labels:  map[string]string{"team": "ops", "env var": "x\ny"},
+	Matrix:  [][]int{{1, 2}, {3}},
+	Main:    cfgServer{Host: "main", Port: 1},
+	Spots:   map[point]string{{1, 2}: "here"},
+	Raw:     []byte("hi"),
+	Inf:     math.Inf(1),
+}
+
+func TestYAML(t *testing.T) {
+	d := scanOne(t, cfgValue)
+	d.SetAbsent(AbsentOmit)
+	b, err := d.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expYAML {
+		t.Error("did not get the expected yaml")
+		t.Log(string(b))
+	}
+
+	// NaN keys sort first, so they do not upset the order of the rest
+	d = scanOne(t, map[float64]int{3: 3, math.NaN(): 0, -1: 1, 2: 2, 0.5: 4})
+	if b, _ = d.YAML(); string(b) != ".nan: 0\n-1.0: 1\n0.5: 4\n2.0: 2\n3.0: 3\n" {
+		t.Errorf("bad yaml float keys %q", b)
+	}
+
+	d = scanOne(t, []string{"a", ""})
+	if b, _ = d.YAML(); string(b) != "- a\n- \"\"\n" {
+		t.Errorf("bad yaml sequence %q", b)
+	}
+}
+
+var expYAML = `Name: "true"
+Version: 3
+Ratio: 2.0
+Servers:
+  - Host: a.example
+    Port: 80
+  - Host: "b: c"
+    Port: 81
+    Debug: true
+Limits:
+  2: two
+  10: ten
+Labels:
+  env var: "x\ny"
+  team: ops
+Matrix:
+  - - 1
+    - 2
+  - - 3
+Main:
+  Host: main
+  Port: 1
+Spots:
+  ? {"X":1,"Y":2}
+  : here
+Raw: !!binary aGk=
+Inf: .inf
+`